@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/naspeh/timefor/tracker"
+	"gopkg.in/yaml.v2"
+)
+
+// Duration is time.Duration which is parsed from strings like 1h20m
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var text string
+	err := unmarshal(&text)
+	if err != nil {
+		return err
+	}
+	return d.UnmarshalText([]byte(text))
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+// Config represents settings from the config file,
+// they are used as defaults for command-line flags
+type Config struct {
+	// File is the path of loaded config file if any
+	File string `yaml:"-" toml:"-"`
+
+	ExpireInterval Duration `yaml:"expire-interval" toml:"expire-interval"`
+	BreakInterval  Duration `yaml:"break-interval" toml:"break-interval"`
+	RepeatInterval Duration `yaml:"repeat-interval" toml:"repeat-interval"`
+	Hook           string   `yaml:"hook" toml:"hook"`
+	IdleCmd        string   `yaml:"idle-cmd" toml:"idle-cmd"`
+	IdleFile       string   `yaml:"idle-file" toml:"idle-file"`
+	IdleThreshold  Duration `yaml:"idle-threshold" toml:"idle-threshold"`
+	IdlePrompt     bool     `yaml:"idle-prompt" toml:"idle-prompt"`
+	Template       string   `yaml:"template" toml:"template"`
+	Menu           string   `yaml:"menu" toml:"menu"`
+	ForgetAfter    int      `yaml:"forget-after" toml:"forget-after"`
+	Hints          bool     `yaml:"hints" toml:"hints"`
+	TimerAction    string   `yaml:"timer-action" toml:"timer-action"`
+	TimerBreak     Duration `yaml:"timer-break" toml:"timer-break"`
+	DayStart       string   `yaml:"day-start" toml:"day-start"`
+
+	// dayStartSet is true if day-start is set in the config file,
+	// only then it's stored for views in the database
+	dayStartSet bool
+}
+
+func defaultConfig() Config {
+	return Config{
+		ExpireInterval: Duration(tracker.DefaultIntervalToExpire),
+		BreakInterval:  Duration(defaultIntervalToShowBreakReminder),
+		RepeatInterval: Duration(defaultIntervalToRepeatBreakReminder),
+		IdleThreshold:  Duration(defaultIdleThreshold),
+		Template:       defaultTpl,
+		Menu:           defaultMenu,
+		ForgetAfter:    defaultForgetAfter,
+		Hints:          true,
+		TimerAction:    defaultTimerAction,
+		TimerBreak:     Duration(defaultTimerBreak),
+		DayStart:       defaultDayStart,
+	}
+}
+
+// configFiles returns possible paths of the config file,
+// CONFIGFILE environment variable is used if specified
+func configFiles() ([]string, error) {
+	if file := os.Getenv("CONFIGFILE"); file != "" {
+		return []string{file}, nil
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		usr, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("cannot get current user: %v", err)
+		}
+		dir = path.Join(usr.HomeDir, ".config")
+	}
+	dir = path.Join(dir, "timefor")
+	return []string{
+		path.Join(dir, "config.toml"),
+		path.Join(dir, "config.yaml"),
+		path.Join(dir, "config.yml"),
+	}, nil
+}
+
+// loadConfig loads the first existing config file, or returns defaults
+// if there is no one
+func loadConfig() (Config, error) {
+	files, err := configFiles()
+	if err != nil {
+		return Config{}, err
+	}
+	explicit := os.Getenv("CONFIGFILE") != ""
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if errors.Is(err, os.ErrNotExist) && !explicit {
+			continue
+		} else if err != nil {
+			return Config{}, err
+		}
+		return parseConfig(file, data)
+	}
+	return defaultConfig(), nil
+}
+
+// parseConfig parses TOML or YAML config depending on the file extension,
+// unknown settings are errors to catch typos
+func parseConfig(file string, data []byte) (Config, error) {
+	config := defaultConfig()
+	config.File = file
+	config.DayStart = ""
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".toml":
+		md, err := toml.Decode(string(data), &config)
+		if err != nil {
+			return Config{}, fmt.Errorf("cannot parse %v: %v", file, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return Config{}, fmt.Errorf("cannot parse %v: unknown setting %v", file, undecoded[0])
+		}
+	case ".yaml", ".yml":
+		err := yaml.UnmarshalStrict(data, &config)
+		if err != nil {
+			return Config{}, fmt.Errorf("cannot parse %v: %v", file, err)
+		}
+	default:
+		return Config{}, fmt.Errorf("cannot parse %v: only .toml, .yaml, .yml are supported", file)
+	}
+	config.dayStartSet = config.DayStart != ""
+	if !config.dayStartSet {
+		config.DayStart = defaultDayStart
+	}
+	if _, err := parseDayStart(config.DayStart); err != nil {
+		return Config{}, fmt.Errorf("cannot parse %v: %v", file, err)
+	}
+	if config.ExpireInterval <= 0 {
+		return Config{}, fmt.Errorf("cannot parse %v: expire-interval must be positive", file)
+	}
+	if config.IdleThreshold <= 0 {
+		return Config{}, fmt.Errorf("cannot parse %v: idle-threshold must be positive", file)
+	}
+	return config, nil
+}
+
+// Show returns effective settings in YAML format
+func (c Config) Show() (string, error) {
+	buf := bytes.Buffer{}
+	if c.File != "" {
+		fmt.Fprintf(&buf, "# %v\n", c.File)
+	} else {
+		fmt.Fprintln(&buf, "# no config file, defaults are used")
+	}
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	buf.Write(data)
+	return buf.String(), nil
+}
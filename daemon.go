@@ -0,0 +1,315 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/naspeh/timefor/tracker"
+)
+
+// idleCheckInterval is how often idle time is checked if an idle probe is set
+const idleCheckInterval = 15 * time.Second
+
+// DaemonOptions specifies what the daemon does besides updating current activity
+type DaemonOptions struct {
+	IntervalToShowBreakReminder   time.Duration
+	IntervalToRepeatBreakReminder time.Duration
+	Hook                          string
+	// IdleCmd prints idle time in milliseconds like xprintidle
+	IdleCmd string
+	// IdleFile is touched on user activity, so its modification time is the last active time
+	IdleFile string
+	// IdleThreshold is idle time after which current activity is finished
+	// at the last active time
+	IdleThreshold time.Duration
+	// IdlePrompt asks using the menu how to resume the activity after idle
+	IdlePrompt bool
+	Menu       string
+	// TimerAction is what to do when the planned duration of current
+	// activity is over: notify, finish or break
+	TimerAction string
+	// TimerBreak is the planned duration of a break started by "break" action
+	TimerBreak time.Duration
+}
+
+func (o DaemonOptions) idleProbe() bool {
+	return o.IdleCmd != "" || o.IdleFile != ""
+}
+
+// idleTime returns user's idle time using the idle command or the idle file
+func (o DaemonOptions) idleTime() (time.Duration, error) {
+	if o.IdleFile != "" {
+		info, err := os.Stat(o.IdleFile)
+		if err != nil {
+			return 0, fmt.Errorf("cannot get idle time: %v", err)
+		}
+		return time.Since(info.ModTime()), nil
+	}
+	out, err := exec.Command("sh", "-c", o.IdleCmd).Output()
+	if err != nil {
+		return 0, fmt.Errorf("cannot get idle time: %v", err)
+	}
+	ms, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse idle time %#v, expected milliseconds", strings.TrimSpace(string(out)))
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// Daemon updates the duration of current activity and runs the hook if specified
+func Daemon(tr *tracker.Tracker, opts DaemonOptions) error {
+	var notified time.Time
+	var lastHook string
+	// idled is the activity finished because of idle
+	var idled tracker.Activity
+	// timed is the id of the last activity with the planned duration over
+	var timed int64
+	// goalsNotified are goals notified in their current periods
+	goalsNotified := map[string]bool{}
+	change := make(chan ChangeEvent)
+
+	if sockFile != "" {
+		listener, err := listenSocket(tr, sockFile)
+		if err != nil {
+			return err
+		}
+		defer listener.Close()
+	}
+
+	go watchDbFile(change)
+
+	for {
+		activity, err := tr.Latest()
+		if err != nil {
+			return err
+		}
+		activity, err = tr.WithGoals(activity)
+		if err != nil {
+			return err
+		}
+		if opts.Hook != "" {
+			cmd, err := activity.Format(opts.Hook)
+			if err != nil {
+				return fmt.Errorf("cannot render hook command: %v", err)
+			}
+			if lastHook != cmd {
+				lastHook = cmd
+				fmt.Printf("running hook command: %s\n", cmd)
+				err = exec.Command("sh", "-c", cmd).Run()
+				if err != nil {
+					return fmt.Errorf("cannot run hook command: %v", err)
+				}
+			}
+		}
+		if opts.idleProbe() {
+			idle, err := opts.idleTime()
+			if err != nil {
+				return err
+			}
+			if idle >= opts.IdleThreshold && activity.Active() {
+				fmt.Printf("idle for %s, finishing %s\n", tracker.FormatDuration(idle), activity.Name)
+				err := tr.FinishAt(activity, tr.Now().Add(-idle), "idle")
+				if err != nil {
+					return err
+				}
+				idled = activity
+				continue
+			} else if idle < opts.IdleThreshold && idled.ID != 0 {
+				fmt.Printf("back after idle, %s was finished\n", idled.Name)
+				if opts.IdlePrompt && activity.ID == idled.ID && !activity.Active() {
+					promptResume(tr, opts.Menu, idled)
+				}
+				idled = tracker.Activity{}
+				continue
+			}
+		}
+		if activity.Active() && activity.PlannedInt > 0 && activity.Countdown() <= 0 && timed != activity.ID {
+			timed = activity.ID
+			err := timeIsUp(tr, opts, activity)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if activity.Active() {
+			for _, g := range activity.Goals {
+				key := fmt.Sprintf("%d %v", g.ID, g.Dates.FromDate())
+				if g.Done() && !goalsNotified[key] {
+					goalsNotified[key] = true
+					notifyGoal(g)
+				}
+			}
+		}
+		if activity.Active() {
+			duration, err := tr.ActiveDuration()
+			if err != nil {
+				return err
+			}
+			if duration > opts.IntervalToShowBreakReminder && time.Since(notified) > opts.IntervalToRepeatBreakReminder {
+				fmt.Printf("sending notification for %s\n", tracker.FormatDuration(duration))
+				args := []string{
+					"Take a break!",
+					fmt.Sprintf("Active for %v already", tracker.FormatDuration(duration)),
+				}
+				if duration.Seconds() > opts.IntervalToShowBreakReminder.Seconds()*1.2 {
+					args = append(args, "-u", "critical")
+				} else {
+					// default timeout is too quick, so set it to 5s
+					args = append(args, "-t", "5000")
+				}
+				notify(args...)
+				notified = time.Now()
+			}
+		}
+
+		nextUpdate := activity.TimeSince().Truncate(time.Minute) + time.Minute - activity.TimeSince()
+		if opts.idleProbe() && nextUpdate > idleCheckInterval {
+			nextUpdate = idleCheckInterval
+		}
+		if countdown := activity.Countdown(); activity.Active() && countdown > 0 && nextUpdate > countdown {
+			nextUpdate = countdown
+		}
+		fmt.Printf("next update in %s\n", nextUpdate)
+
+		select {
+		case c := <-change:
+			fmt.Println("change", c)
+			if c.Error != nil {
+				return c.Error
+			}
+
+		case <-time.After(nextUpdate):
+			if activity.Active() && tr.Now().Sub(activity.Updated()) > time.Minute {
+				fmt.Printf("updating time for %s\n", activity.Name)
+				_, err := tr.UpdateIfExists("", false)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// timeIsUp notifies that the planned duration of the activity is over and
+// finishes it at the planned end or switches to a break if it's configured
+func timeIsUp(tr *tracker.Tracker, opts DaemonOptions, activity tracker.Activity) error {
+	planned := tracker.FormatDuration(activity.Planned())
+	fmt.Printf("time is up for %s planned for %s\n", activity.Name, planned)
+	notify("Time is up!", fmt.Sprintf("%s planned for %v is over", activity.Name, planned), "-u", "critical")
+
+	end := activity.Started().Add(activity.Planned())
+	switch opts.TimerAction {
+	case "finish":
+		return tr.FinishAt(activity, end, "timer")
+	case "break":
+		if activity.Name == defaultBreakName {
+			return nil
+		}
+		err := tr.FinishAt(activity, end, "timer")
+		if err != nil {
+			return err
+		}
+		shift := time.Duration(tr.Now().Unix()-end.Unix()) * time.Second
+		return tr.Start(defaultBreakName, shift, "", opts.TimerBreak)
+	}
+	return nil
+}
+
+// notifyGoal notifies that the target is reached or the limit is exceeded
+func notifyGoal(g tracker.GoalStatus) {
+	fmt.Printf("goal %s for %s is %s\n", g, g.Tag, g.Status())
+	if g.Max {
+		notify(
+			"Limit exceeded!",
+			fmt.Sprintf("%v of %v, the limit is %v per %v", tracker.FormatDuration(g.Spent), g.Tag, tracker.FormatDuration(g.Duration()), g.Period),
+			"-u", "critical",
+		)
+		return
+	}
+	notify(
+		"Goal reached!",
+		fmt.Sprintf("%v of %v per %v", tracker.FormatDuration(g.Spent), g.Tag, g.Period),
+		"-t", "5000",
+	)
+}
+
+// notify sends a desktop notification using notify-send, errors are printed
+// only to keep the daemon running
+func notify(args ...string) {
+	err := exec.Command("notify-send", args...).Run()
+	if err != nil {
+		fmt.Printf("cannot send notification: %v\n", err)
+	}
+}
+
+// promptResume asks using the menu how to resume the activity finished
+// because of idle, errors are printed only to keep the daemon running
+func promptResume(tr *tracker.Tracker, menu string, activity tracker.Activity) {
+	options := []string{
+		fmt.Sprintf("Resume %s, idle time was a break", activity.Name),
+		fmt.Sprintf("Resume %s, count idle time to it", activity.Name),
+		"Don't resume",
+	}
+	var out string
+	selected, err := runMenu(menu, "back", options)
+	if err == nil {
+		switch selected {
+		case options[0]:
+			out, err = tr.Resume(false, defaultBreakName)
+		case options[1]:
+			out, err = tr.Resume(true, "")
+		case options[2]:
+		default:
+			err = errors.New("unknown option")
+		}
+	}
+	if err != nil {
+		fmt.Printf("cannot resume activity: %v\n", err)
+		return
+	}
+	fmt.Print(out)
+}
+
+type ChangeEvent struct {
+	Event fsnotify.Event
+	Error error
+}
+
+func watchDbFile(change chan ChangeEvent) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				change <- ChangeEvent{Event: event}
+
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				change <- ChangeEvent{Error: err}
+			}
+		}
+	}()
+
+	if err := watcher.Add(dbFile); err != nil {
+		return err
+	}
+
+	<-make(chan struct{})
+	return nil
+}
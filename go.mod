@@ -3,6 +3,7 @@ module github.com/naspeh/timefor
 go 1.15
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/cpuguy83/go-md2man/v2 v2.0.3 // indirect
 	github.com/fsnotify/fsnotify v1.7.0
 	github.com/google/go-cmp v0.6.0
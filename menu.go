@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// menus are built-in menu commands, each of them reads newline-delimited
+// options from stdin and prints the selected option or typed text to stdout
+var menus = map[string][]string{
+	"rofi":   {"rofi", "-dmenu", "-p", "{{.Prompt}}"},
+	"wofi":   {"wofi", "--dmenu", "--prompt", "{{.Prompt}}"},
+	"fuzzel": {"fuzzel", "--dmenu", "--prompt", "{{.Prompt}}: "},
+	"bemenu": {"bemenu", "-p", "{{.Prompt}}"},
+	"dmenu":  {"dmenu", "-p", "{{.Prompt}}"},
+	// prints the query if nothing matches, so new activities can be typed
+	"fzf": {"fzf", "--prompt", "{{.Prompt}}: ", "--bind", "enter:accept-or-print-query"},
+}
+
+func menuNames() []string {
+	var names []string
+	for name := range menus {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// menuCommand returns the command for a built-in menu or for a custom
+// command template, which is executed by "sh -c"
+func menuCommand(menu, prompt string) (*exec.Cmd, error) {
+	args, ok := menus[menu]
+	if !ok {
+		args = []string{"sh", "-c", menu}
+	}
+	data := struct{ Prompt string }{Prompt: prompt}
+	rendered := make([]string, len(args))
+	for i, arg := range args {
+		var buf bytes.Buffer
+		t, err := template.New("menu").Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse menu command: %v", err)
+		}
+		err = t.Execute(&buf, data)
+		if err != nil {
+			return nil, fmt.Errorf("cannot render menu command: %v", err)
+		}
+		rendered[i] = buf.String()
+	}
+	return exec.Command(rendered[0], rendered[1:]...), nil
+}
+
+// runMenu returns an option selected by the menu
+func runMenu(menu, prompt string, options []string) (string, error) {
+	cmd, err := menuCommand(menu, prompt)
+	if err != nil {
+		return "", err
+	}
+
+	cmdIn, err := cmd.StdinPipe()
+	if err != nil {
+		return "", err
+	}
+
+	cmdOut, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return "", fmt.Errorf("cannot run menu: %v", err)
+	}
+	for _, option := range options {
+		fmt.Fprintln(cmdIn, option)
+	}
+	cmdIn.Close()
+	selected, err := io.ReadAll(cmdOut)
+	if err != nil {
+		return "", err
+	}
+	err = cmd.Wait()
+	if err != nil {
+		return "", fmt.Errorf("cannot get selection from menu: %v", err)
+	}
+	option := strings.TrimSpace(string(selected))
+	if option == "" {
+		return "", errors.New("nothing selected")
+	}
+	return option, nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/naspeh/timefor/tracker"
+)
+
+// socketTimeout limits how long a client waits for the daemon
+const socketTimeout = 10 * time.Second
+
+// Request is a JSON request to the daemon over the Unix socket, like
+// {"command":"start","name":"@go","planned":"25m"}
+type Request struct {
+	// Command is one of show, start, finish, report
+	Command string `json:"command"`
+	// Template is used by show
+	Template string `json:"template,omitempty"`
+	// Name, Shift, Note and Planned are used by start
+	Name    string   `json:"name,omitempty"`
+	Shift   Duration `json:"shift,omitempty"`
+	Note    string   `json:"note,omitempty"`
+	Planned Duration `json:"planned,omitempty"`
+	// Report, Format and Notify are used by report
+	Report tracker.ReportOptions `json:"report"`
+	Format string                `json:"format,omitempty"`
+	Notify bool                  `json:"notify,omitempty"`
+}
+
+// Response is a JSON response from the daemon with the output of the
+// command or its error
+type Response struct {
+	Output string `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// socketFile returns the path of the daemon socket, SOCKFILE environment
+// variable is used if specified, it's empty if there is no place for it
+func socketFile() string {
+	if file := os.Getenv("SOCKFILE"); file != "" {
+		return file
+	}
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		return ""
+	}
+	return path.Join(dir, "timefor.sock")
+}
+
+// Call sends the request to the daemon if it's running, otherwise the
+// request is handled directly using the database
+func Call(tr *tracker.Tracker, req Request) (string, error) {
+	if sockFile != "" {
+		conn, err := net.DialTimeout("unix", sockFile, time.Second)
+		if err == nil {
+			defer conn.Close()
+			return sendRequest(conn, req)
+		}
+	}
+	err := tr.Init()
+	if err != nil {
+		return "", fmt.Errorf("cannot initiate SQLite database: %v", err)
+	}
+	return handleRequest(tr, req)
+}
+
+// call prints the output of the request if any
+func call(tr *tracker.Tracker, req Request) error {
+	out, err := Call(tr, req)
+	if err != nil {
+		return err
+	}
+	if out != "" {
+		fmt.Println(out)
+	}
+	return nil
+}
+
+func sendRequest(conn net.Conn, req Request) (string, error) {
+	err := conn.SetDeadline(time.Now().Add(socketTimeout))
+	if err != nil {
+		return "", err
+	}
+	err = json.NewEncoder(conn).Encode(req)
+	if err != nil {
+		return "", fmt.Errorf("cannot send request to daemon: %v", err)
+	}
+	var resp Response
+	err = json.NewDecoder(conn).Decode(&resp)
+	if err != nil {
+		return "", fmt.Errorf("cannot get response from daemon: %v", err)
+	}
+	if resp.Error != "" {
+		return "", errors.New(resp.Error)
+	}
+	return resp.Output, nil
+}
+
+// handleRequest runs the command from the request and returns its output
+func handleRequest(tr *tracker.Tracker, req Request) (string, error) {
+	switch req.Command {
+	case "show":
+		activity, err := tr.Latest()
+		if err != nil {
+			return "", err
+		}
+		activity, err = tr.WithGoals(activity)
+		if err != nil {
+			return "", err
+		}
+		return activity.Format(req.Template)
+
+	case "start":
+		planned := time.Duration(req.Planned)
+		err := tr.Start(req.Name, time.Duration(req.Shift), req.Note, planned)
+		if err != nil {
+			return "", err
+		}
+		name := strings.TrimSpace(req.Name)
+		if planned > 0 {
+			return fmt.Sprintf("New activity %#v started for %v", name, tracker.FormatDuration(planned)), nil
+		}
+		return fmt.Sprintf("New activity %#v started", name), nil
+
+	case "finish":
+		return "", tr.Update("", true)
+
+	case "report":
+		report, err := tr.Report(req.Report)
+		if err != nil {
+			return "", err
+		}
+		if req.Format != "" && req.Format != "text" {
+			out, err := report.Format(req.Format)
+			return strings.TrimSpace(out), err
+		}
+		title, desc := report.Title(), report.Text()
+		if req.Notify {
+			notify("-t", "0", title, desc)
+			return "", nil
+		}
+		return fmt.Sprintf("%v\n\n%v", title, desc), nil
+	}
+	return "", fmt.Errorf("unknown command %#v", req.Command)
+}
+
+// listenSocket serves requests to the daemon over the Unix socket, a socket
+// file left by a killed daemon is replaced
+func listenSocket(tr *tracker.Tracker, file string) (net.Listener, error) {
+	conn, err := net.Dial("unix", file)
+	if err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("daemon is already running, %v is in use", file)
+	}
+	os.Remove(file)
+
+	listener, err := net.Listen("unix", file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen on socket: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveConn(tr, conn)
+		}
+	}()
+	return listener, nil
+}
+
+func serveConn(tr *tracker.Tracker, conn net.Conn) {
+	defer conn.Close()
+	err := conn.SetDeadline(time.Now().Add(socketTimeout))
+	if err != nil {
+		return
+	}
+
+	var req Request
+	var resp Response
+	err = json.NewDecoder(conn).Decode(&req)
+	if err != nil {
+		err = fmt.Errorf("cannot parse request: %v", err)
+	} else {
+		resp.Output, err = handleRequest(tr, req)
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	err = json.NewEncoder(conn).Encode(resp)
+	if err != nil {
+		fmt.Printf("cannot send response: %v\n", err)
+	}
+}
@@ -1,35 +1,41 @@
 package main
 
 import (
-	"bytes"
-	"database/sql"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"os/exec"
 	"os/user"
 	"path"
+	"strconv"
 	"strings"
-	"text/tabwriter"
-	"text/template"
 	"time"
 
-	"github.com/fsnotify/fsnotify"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/naspeh/timefor/tracker"
 	"github.com/urfave/cli/v2"
 )
 
 const (
-	intervalToExpire                     = 10 * time.Minute
 	defaultIntervalToShowBreakReminder   = 80 * time.Minute
 	defaultIntervalToRepeatBreakReminder = 10 * time.Minute
 	defaultTpl                           = "{{if .Active}}☭{{else}}☯{{end}} {{.FormatLabel}}"
+	defaultMenu                          = "rofi"
+	defaultForgetAfter                   = 90
+	defaultBreakName                     = "@break"
+	defaultIdleThreshold                 = 5 * time.Minute
+	defaultTimerAction                   = "notify"
+	defaultTimerBreak                    = 5 * time.Minute
+	defaultDayStart                      = "00:00"
 )
 
-var dbFile string
+var (
+	dbFile string
+	// sockFile is the daemon socket, it's empty if the daemon shouldn't be used
+	sockFile string
+)
 
 func main() {
 	dbFile = os.Getenv("DBFILE")
@@ -45,23 +51,78 @@ func main() {
 		log.Fatalf("cannot open SQLite database: %v", err)
 	}
 	defer db.Close()
+	sockFile = socketFile()
 
-	err = initDb(db)
+	config, err := loadConfig()
 	if err != nil {
-		log.Fatalf("cannot initiate SQLite database: %v", err)
+		log.Fatalf("cannot load config: %v", err)
 	}
 
-	err = newCmd(db)
+	err = newCmd(db, config)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func newCmd(db *sqlx.DB) error {
+func newCmd(db *sqlx.DB, config Config) error {
+	// the tracker is created in Before as its options come from flags
+	var tr *tracker.Tracker
 	app := &cli.App{
 		Name:  "timefor",
 		Usage: "A command-line time tracker with rofi integration",
+		Flags: []cli.Flag{
+			&cli.DurationFlag{
+				Name:  "expire-interval",
+				Usage: "interval after which not updated activity is inactive",
+				Value: time.Duration(config.ExpireInterval),
+				Action: func(ctx *cli.Context, v time.Duration) error {
+					if v <= 0 {
+						return errors.New("an expire interval must be positive")
+					}
+					return nil
+				},
+			},
+			&cli.StringFlag{
+				Name:    "now",
+				Usage:   "use the fixed time as the current one (like 2006-01-02 15:04:05)",
+				EnvVars: []string{"TIMEFOR_NOW"},
+				Hidden:  true,
+			},
+		},
+		Before: func(cCtx *cli.Context) error {
+			dayStart, _ := parseDayStart(config.DayStart)
+			opts := tracker.Options{
+				IntervalToExpire: cCtx.Duration("expire-interval"),
+				DayStart:         dayStart,
+			}
+			if cCtx.IsSet("now") {
+				now, err := parseNow(cCtx.String("now"))
+				if err != nil {
+					return err
+				}
+				opts.Clock = tracker.FixedClock(now)
+			}
+			tr = tracker.New(tracker.DBStore(db), opts)
+			switch cCtx.Args().First() {
+			case "show", "start", "finish", "report":
+				// these commands go through the daemon if it's running,
+				// so the database is initiated only if it's needed, but
+				// the daemon uses its own expire interval and the system clock
+				if cCtx.IsSet("expire-interval") || cCtx.IsSet("now") {
+					sockFile = ""
+				}
+				return nil
+			}
+			err := tr.Init()
+			if err != nil {
+				return fmt.Errorf("cannot initiate SQLite database: %v", err)
+			}
+			if config.dayStartSet {
+				return tr.StoreDayStart()
+			}
+			return nil
+		},
 		Commands: []*cli.Command{
 			{
 				Name:      "start",
@@ -78,6 +139,60 @@ func newCmd(db *sqlx.DB) error {
 							return nil
 						},
 					},
+					&cli.StringFlag{
+						Name:  "note",
+						Usage: "a free-text note",
+					},
+					&cli.DurationFlag{
+						Name:  "for",
+						Usage: "a planned duration for a countdown (like 25m)",
+						Action: func(ctx *cli.Context, v time.Duration) error {
+							if v <= 0 {
+								return errors.New("a duration must be positive")
+							}
+							return nil
+						},
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Args().Len() != 1 {
+						return cli.ShowSubcommandHelp(cCtx)
+					}
+
+					return call(tr, Request{
+						Command: "start",
+						Name:    cCtx.Args().First(),
+						Shift:   Duration(cCtx.Duration("shift")),
+						Note:    cCtx.String("note"),
+						Planned: Duration(cCtx.Duration("for")),
+					})
+				},
+			},
+			{
+				Name:      "add",
+				Usage:     "Add finished activity in the past",
+				ArgsUsage: "[activity name]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "at",
+						Usage:    "the start time (like 15:04 or 2006-01-02 15:04)",
+						Required: true,
+					},
+					&cli.DurationFlag{
+						Name:     "for",
+						Usage:    "the duration (like 45m, 1h30m)",
+						Required: true,
+						Action: func(ctx *cli.Context, v time.Duration) error {
+							if v <= 0 {
+								return errors.New("a duration must be positive")
+							}
+							return nil
+						},
+					},
+					&cli.StringFlag{
+						Name:  "note",
+						Usage: "a free-text note",
+					},
 				},
 				Action: func(cCtx *cli.Context) error {
 					if cCtx.Args().Len() != 1 {
@@ -85,13 +200,21 @@ func newCmd(db *sqlx.DB) error {
 					}
 
 					name := cCtx.Args().First()
-					shift := cCtx.Duration("shift")
-					return Start(db, name, shift)
+					started, err := parseTime(cCtx.String("at"), tr.Now())
+					if err != nil {
+						return err
+					}
+					out, err := tr.Add(name, started, cCtx.Duration("for"), cCtx.String("note"))
+					if err != nil {
+						return err
+					}
+					fmt.Print(out)
+					return nil
 				},
 			},
 			{
 				Name:      "select",
-				Usage:     "Select new activity using rofi",
+				Usage:     "Select new activity using rofi or another menu",
 				ArgsUsage: " ",
 				Flags: []cli.Flag{
 					&cli.BoolFlag{
@@ -99,6 +222,21 @@ func newCmd(db *sqlx.DB) error {
 						Usage: "update current activity instead",
 						Value: false,
 					},
+					&cli.StringFlag{
+						Name:  "menu",
+						Usage: "a menu: " + strings.Join(menuNames(), ", ") + " or a custom command template",
+						Value: config.Menu,
+					},
+					&cli.IntFlag{
+						Name:  "forget-after",
+						Usage: "skip names unused for the number of days (0 to keep all)",
+						Value: config.ForgetAfter,
+					},
+					&cli.BoolFlag{
+						Name:  "hints",
+						Usage: "show hints like today's total next to names (--hints=false to hide)",
+						Value: config.Hints,
+					},
 				},
 				Action: func(cCtx *cli.Context) error {
 					if cCtx.Args().Present() {
@@ -106,15 +244,18 @@ func newCmd(db *sqlx.DB) error {
 					}
 
 					update := cCtx.Bool("update")
-					name, err := Select(db)
+					prompt := "start"
+					if update {
+						prompt = "update"
+					}
+					name, err := Select(tr, cCtx.String("menu"), prompt, cCtx.Int("forget-after"), cCtx.Bool("hints"))
 					if err != nil {
 						return err
 					}
 					if update {
-						return Update(db, name, false)
+						return tr.Update(name, false)
 					}
-					return Start(db, name, 0)
-
+					return call(tr, Request{Command: "start", Name: name})
 				},
 			},
 			{
@@ -133,7 +274,7 @@ func newCmd(db *sqlx.DB) error {
 					}
 
 					name := cCtx.String("name")
-					return Update(db, name, false)
+					return tr.Update(name, false)
 				},
 			},
 			{
@@ -145,7 +286,52 @@ func newCmd(db *sqlx.DB) error {
 						return cli.ShowSubcommandHelp(cCtx)
 					}
 
-					return Update(db, "", true)
+					return call(tr, Request{Command: "finish"})
+				},
+			},
+			{
+				Name:      "note",
+				Usage:     "Set a note for current activity",
+				ArgsUsage: "[note]",
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Args().Len() != 1 {
+						return cli.ShowSubcommandHelp(cCtx)
+					}
+
+					return tr.Note(cCtx.Args().First())
+				},
+			},
+			{
+				Name:      "resume",
+				Usage:     "Resume the latest activity after it's expired or finished",
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "gap",
+						Usage: "record the gap as: break, fill (count it to the activity)",
+						Value: "break",
+					},
+					&cli.StringFlag{
+						Name:  "break-name",
+						Usage: "activity name for the break",
+						Value: defaultBreakName,
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Args().Present() {
+						return cli.ShowSubcommandHelp(cCtx)
+					}
+
+					gap := cCtx.String("gap")
+					if gap != "break" && gap != "fill" {
+						return fmt.Errorf("cannot record the gap as %#v, use break or fill", gap)
+					}
+					out, err := tr.Resume(gap == "fill", cCtx.String("break-name"))
+					if err != nil {
+						return err
+					}
+					fmt.Print(out)
+					return nil
 				},
 			},
 			{
@@ -157,7 +343,49 @@ func newCmd(db *sqlx.DB) error {
 						return cli.ShowSubcommandHelp(cCtx)
 					}
 
-					return Reject(db)
+					return tr.Reject()
+				},
+			},
+			{
+				Name:      "undo",
+				Usage:     "Undo the latest change made by a command",
+				ArgsUsage: " ",
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Args().Present() {
+						return cli.ShowSubcommandHelp(cCtx)
+					}
+
+					out, err := tr.Undo()
+					if err != nil {
+						return err
+					}
+					fmt.Print(out)
+					return nil
+				},
+			},
+			{
+				Name:      "history",
+				Usage:     "List recent commands which changed activities",
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:    "limit",
+						Aliases: []string{"n"},
+						Usage:   "the number of commands to list",
+						Value:   10,
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Args().Present() {
+						return cli.ShowSubcommandHelp(cCtx)
+					}
+
+					out, err := tr.History(cCtx.Int("limit"))
+					if err != nil {
+						return err
+					}
+					fmt.Print(out)
+					return nil
 				},
 			},
 			{
@@ -169,7 +397,7 @@ func newCmd(db *sqlx.DB) error {
 						Name:    "template",
 						Aliases: []string{"t"},
 						Usage:   "template for formatting",
-						Value:   defaultTpl,
+						Value:   config.Template,
 					},
 				},
 				Action: func(cCtx *cli.Context) error {
@@ -177,42 +405,143 @@ func newCmd(db *sqlx.DB) error {
 						return cli.ShowSubcommandHelp(cCtx)
 					}
 
-					tpl := cCtx.String("template")
-					return Show(db, tpl)
+					return call(tr, Request{Command: "show", Template: cCtx.String("template")})
 				},
 			},
 			{
-				Name:      "report",
-				Usage:     "Report today's activities",
+				Name:      "watch",
+				Usage:     "Print current activity whenever it changes (for status bars)",
 				ArgsUsage: " ",
 				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "format",
+						Aliases: []string{"f"},
+						Usage:   "output format: " + strings.Join(watchFormats, ", "),
+						Value:   "plain",
+					},
+					&cli.StringFlag{
+						Name:    "template",
+						Aliases: []string{"t"},
+						Usage:   "template for formatting",
+						Value:   config.Template,
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Args().Present() {
+						return cli.ShowSubcommandHelp(cCtx)
+					}
+
+					format := cCtx.String("format")
+					_, err := statusLine(tracker.Activity{}, format, "")
+					if err != nil {
+						return err
+					}
+					return Watch(tr, format, cCtx.String("template"))
+				},
+			},
+			{
+				Name:      "report",
+				Usage:     "Report activities for today or a date range",
+				ArgsUsage: " ",
+				Flags: append([]cli.Flag{
 					&cli.BoolFlag{
 						Name:    "notify",
 						Aliases: []string{"n"},
 						Usage:   "Notify using notify-send",
 						Value:   false,
 					},
-				},
+					&cli.StringFlag{
+						Name:    "format",
+						Aliases: []string{"f"},
+						Usage:   "output format: text, json, csv, tsv",
+						Value:   "text",
+					},
+					&cli.StringFlag{
+						Name:  "tag",
+						Usage: "report only activities with the tag (including subtags)",
+					},
+					&cli.StringFlag{
+						Name:  "by",
+						Usage: "group activities by: name, tag",
+						Value: "name",
+					},
+					&cli.BoolFlag{
+						Name:    "verbose",
+						Aliases: []string{"v"},
+						Usage:   "show notes of activities as well",
+						Value:   false,
+					},
+					&cli.BoolFlag{
+						Name:  "timesheet",
+						Usage: "show a matrix with days as columns (like with --week)",
+						Value: false,
+					},
+					&cli.StringFlag{
+						Name:  "html",
+						Usage: "save the report as a self-contained HTML page to the file",
+					},
+				}, rangeFlags()...),
 				Action: func(cCtx *cli.Context) error {
 					if cCtx.Args().Present() {
 						return cli.ShowSubcommandHelp(cCtx)
 					}
 					notify := cCtx.Bool("notify")
-					title, desc, err := Report(db)
+					format := cCtx.String("format")
+					if notify && format != "text" {
+						return errors.New("only text format can be used with --notify")
+					}
+					timesheet := cCtx.Bool("timesheet")
+					if timesheet && format != "text" {
+						return errors.New("only text format can be used with --timesheet")
+					}
+					html := cCtx.String("html")
+					// the page always has totals per day, but no notes
+					if html != "" && (notify || format != "text" || timesheet || cCtx.Bool("verbose")) {
+						return errors.New("none of --notify, --format, --timesheet, --verbose can be used with --html")
+					}
+					dates, err := parseRange(cCtx, tr.Today())
 					if err != nil {
 						return err
 					}
-					if notify {
-						args := []string{"-t", "0", title, desc}
-						err := exec.Command("notify-send", args...).Run()
+					// a timesheet has columns for all days of the week or the month
+					if timesheet && cCtx.Bool("week") {
+						dates.To = dates.From.AddDate(0, 0, 6)
+					} else if timesheet && cCtx.Bool("month") {
+						dates.To = dates.From.AddDate(0, 1, -1)
+					}
+					by := cCtx.String("by")
+					if by != "name" && by != "tag" {
+						return fmt.Errorf("cannot group by %#v, use name or tag", by)
+					}
+					opts := tracker.ReportOptions{
+						Dates:     dates,
+						Tag:       strings.TrimLeft(cCtx.String("tag"), "@+"),
+						ByTag:     by == "tag",
+						Verbose:   cCtx.Bool("verbose"),
+						Timesheet: timesheet,
+					}
+					if html != "" {
+						err := tr.Init()
+						if err != nil {
+							return fmt.Errorf("cannot initiate SQLite database: %v", err)
+						}
+						page, err := tr.HTML(opts)
 						if err != nil {
-							log.Printf("cannot send notification: %v", err)
+							return err
 						}
+						err = os.WriteFile(html, []byte(page), 0644)
+						if err != nil {
+							return fmt.Errorf("cannot save report: %v", err)
+						}
+						fmt.Printf("Report saved to %v\n", html)
 						return nil
 					}
-					fmt.Printf("%v\n\n", title)
-					fmt.Println(desc)
-					return nil
+					return call(tr, Request{
+						Command: "report",
+						Report:  opts,
+						Format:  format,
+						Notify:  notify,
+					})
 				},
 			},
 			{
@@ -223,34 +552,345 @@ func newCmd(db *sqlx.DB) error {
 					&cli.DurationFlag{
 						Name:  "break-interval",
 						Usage: "interval to show a break reminder",
-						Value: defaultIntervalToShowBreakReminder,
+						Value: time.Duration(config.BreakInterval),
 					},
 					&cli.DurationFlag{
 						Name:  "repeat-interval",
 						Usage: "interval to repeat a break reminder",
-						Value: defaultIntervalToRepeatBreakReminder,
+						Value: time.Duration(config.RepeatInterval),
 					},
 					&cli.StringFlag{
 						Name:  "hook",
 						Usage: "a hook command template",
+						Value: config.Hook,
+					},
+					&cli.StringFlag{
+						Name:  "idle-cmd",
+						Usage: "a command printing idle time in milliseconds (like xprintidle)",
+						Value: config.IdleCmd,
+					},
+					&cli.StringFlag{
+						Name:  "idle-file",
+						Usage: "a file touched on user activity, an alternative to --idle-cmd",
+						Value: config.IdleFile,
+					},
+					&cli.DurationFlag{
+						Name:  "idle-threshold",
+						Usage: "idle time to finish current activity at the last active time",
+						Value: time.Duration(config.IdleThreshold),
+						Action: func(ctx *cli.Context, v time.Duration) error {
+							if v <= 0 {
+								return errors.New("an idle threshold must be positive")
+							}
+							return nil
+						},
+					},
+					&cli.BoolFlag{
+						Name:  "idle-prompt",
+						Usage: "ask using the menu how to resume the activity after idle",
+						Value: config.IdlePrompt,
 					},
+					&cli.StringFlag{
+						Name:  "menu",
+						Usage: "a menu for --idle-prompt: " + strings.Join(menuNames(), ", ") + " or a custom command template",
+						Value: config.Menu,
+					},
+					&cli.StringFlag{
+						Name:  "timer-action",
+						Usage: "what to do when a planned duration is over: notify, finish, break",
+						Value: config.TimerAction,
+					},
+					&cli.DurationFlag{
+						Name:  "timer-break",
+						Usage: "a planned duration of a break started by --timer-action break",
+						Value: time.Duration(config.TimerBreak),
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Args().Present() {
+						return cli.ShowSubcommandHelp(cCtx)
+					}
+
+					opts := DaemonOptions{
+						IntervalToShowBreakReminder:   cCtx.Duration("break-interval"),
+						IntervalToRepeatBreakReminder: cCtx.Duration("repeat-interval"),
+						Hook:                          cCtx.String("hook"),
+						IdleCmd:                       cCtx.String("idle-cmd"),
+						IdleFile:                      cCtx.String("idle-file"),
+						IdleThreshold:                 cCtx.Duration("idle-threshold"),
+						IdlePrompt:                    cCtx.Bool("idle-prompt"),
+						Menu:                          cCtx.String("menu"),
+						TimerAction:                   cCtx.String("timer-action"),
+						TimerBreak:                    cCtx.Duration("timer-break"),
+					}
+					if opts.IdleCmd != "" && opts.IdleFile != "" {
+						return errors.New("only one of --idle-cmd, --idle-file can be used")
+					}
+					switch opts.TimerAction {
+					case "notify", "finish", "break":
+					default:
+						return fmt.Errorf("unknown timer action %#v, use notify, finish or break", opts.TimerAction)
+					}
+					err := Daemon(tr, opts)
+					if err != nil {
+						return err
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "export",
+				Usage:     "Export activities for today or a date range",
+				ArgsUsage: " ",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:    "format",
+						Aliases: []string{"f"},
+						Usage:   "output format: " + strings.Join(tracker.ExportFormats, ", "),
+						Value:   "json",
+					},
+				}, rangeFlags()...),
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Args().Present() {
+						return cli.ShowSubcommandHelp(cCtx)
+					}
+
+					dates, err := parseRange(cCtx, tr.Today())
+					if err != nil {
+						return err
+					}
+					out, err := tr.Export(dates, cCtx.String("format"))
+					if err != nil {
+						return err
+					}
+					fmt.Print(out)
+					return nil
 				},
+			},
+			{
+				Name:      "log",
+				Usage:     "List activities with their ids",
+				ArgsUsage: " ",
+				Flags:     rangeFlags(),
 				Action: func(cCtx *cli.Context) error {
 					if cCtx.Args().Present() {
 						return cli.ShowSubcommandHelp(cCtx)
 					}
 
-					intervalToShowBreakReminder := cCtx.Duration("break-interval")
-					intervalToRepeatBreakReminder := cCtx.Duration("repeat-interval")
-					hook := cCtx.String("hook")
+					dates, err := parseRange(cCtx, tr.Today())
+					if err != nil {
+						return err
+					}
+					out, err := tr.List(dates)
+					if err != nil {
+						return err
+					}
+					fmt.Print(out)
+					return nil
+				},
+			},
+			{
+				Name:      "edit",
+				Usage:     "Edit an activity by id",
+				ArgsUsage: "[activity id]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "name",
+						Usage: "a new name",
+					},
+					&cli.StringFlag{
+						Name:  "started",
+						Usage: "a new start time (like 15:04 or 2006-01-02 15:04)",
+					},
+					&cli.DurationFlag{
+						Name:  "duration",
+						Usage: "a new duration (like 45m, 1h30m)",
+						Action: func(ctx *cli.Context, v time.Duration) error {
+							if v <= 0 {
+								return errors.New("a duration must be positive")
+							}
+							return nil
+						},
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Args().Len() != 1 {
+						return cli.ShowSubcommandHelp(cCtx)
+					}
+
+					id, err := strconv.ParseInt(cCtx.Args().First(), 10, 64)
+					if err != nil {
+						return fmt.Errorf("cannot parse activity id %#v", cCtx.Args().First())
+					}
+					activity, err := tr.Get(id)
+					if err != nil {
+						return err
+					}
+
+					var started time.Time
+					if cCtx.IsSet("started") {
+						started, err = parseTime(cCtx.String("started"), activity.Started())
+						if err != nil {
+							return err
+						}
+					}
+					name := cCtx.String("name")
+					duration := cCtx.Duration("duration")
+					if strings.TrimSpace(name) == "" && started.IsZero() && duration == 0 {
+						return errors.New("nothing to change, use --name, --started or --duration")
+					}
+					out, err := tr.Edit(activity, name, started, duration)
+					if err != nil {
+						return err
+					}
+					fmt.Print(out)
+					return nil
+				},
+			},
+			{
+				Name:      "import",
+				Usage:     "Import activities from another tracker",
+				ArgsUsage: "[file]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "from",
+						Usage:    "a format of the file: " + strings.Join(tracker.ImportFormats, ", "),
+						Required: true,
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "list activities without inserting them",
+						Value: false,
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Args().Len() != 1 {
+						return cli.ShowSubcommandHelp(cCtx)
+					}
 
-					err := Daemon(db, intervalToShowBreakReminder, intervalToRepeatBreakReminder, hook)
+					file, err := os.Open(cCtx.Args().First())
+					if err != nil {
+						return fmt.Errorf("cannot open file: %v", err)
+					}
+					defer file.Close()
+					entries, err := tracker.ParseImport(cCtx.String("from"), file)
+					if err != nil {
+						return err
+					}
+					out, err := tr.Import(entries, cCtx.Bool("dry-run"))
 					if err != nil {
 						return err
 					}
+					fmt.Print(out)
 					return nil
 				},
 			},
+			{
+				Name:  "goal",
+				Usage: "Set daily or weekly goals for tags",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "set",
+						Usage:     "Set a target or a limit (with --max) for a tag",
+						ArgsUsage: "[tag] [duration]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "per",
+								Usage: "a period of the goal: day, week",
+								Value: "day",
+							},
+							&cli.BoolFlag{
+								Name:  "max",
+								Usage: "set a limit instead of a target",
+								Value: false,
+							},
+						},
+						Action: func(cCtx *cli.Context) error {
+							if cCtx.Args().Len() != 2 {
+								return cli.ShowSubcommandHelp(cCtx)
+							}
+
+							period, err := parsePeriod(cCtx.String("per"))
+							if err != nil {
+								return err
+							}
+							duration, err := time.ParseDuration(cCtx.Args().Get(1))
+							if err != nil || duration <= 0 {
+								return fmt.Errorf("cannot parse duration %#v, expected positive duration (like 4h, 1h30m)", cCtx.Args().Get(1))
+							}
+							out, err := tr.SetGoal(cCtx.Args().First(), period, cCtx.Bool("max"), duration)
+							if err != nil {
+								return err
+							}
+							fmt.Print(out)
+							return nil
+						},
+					},
+					{
+						Name:      "list",
+						Usage:     "List goals with their progress",
+						ArgsUsage: " ",
+						Action: func(cCtx *cli.Context) error {
+							if cCtx.Args().Present() {
+								return cli.ShowSubcommandHelp(cCtx)
+							}
+
+							out, err := tr.ListGoals()
+							if err != nil {
+								return err
+							}
+							fmt.Print(out)
+							return nil
+						},
+					},
+					{
+						Name:      "delete",
+						Usage:     "Delete a goal for a tag",
+						ArgsUsage: "[tag]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "per",
+								Usage: "a period of the goal: day, week",
+								Value: "day",
+							},
+						},
+						Action: func(cCtx *cli.Context) error {
+							if cCtx.Args().Len() != 1 {
+								return cli.ShowSubcommandHelp(cCtx)
+							}
+
+							period, err := parsePeriod(cCtx.String("per"))
+							if err != nil {
+								return err
+							}
+							return tr.DeleteGoal(cCtx.Args().First(), period)
+						},
+					},
+				},
+			},
+			{
+				Name:  "config",
+				Usage: "Show settings from the config file",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "show",
+						Usage:     "Show effective settings",
+						ArgsUsage: " ",
+						Action: func(cCtx *cli.Context) error {
+							if cCtx.Args().Present() {
+								return cli.ShowSubcommandHelp(cCtx)
+							}
+
+							out, err := config.Show()
+							if err != nil {
+								return err
+							}
+							fmt.Print(out)
+							return nil
+						},
+					},
+				},
+			},
 			{
 				Name:      "db",
 				Usage:     "Execute sqlite3 with db file",
@@ -262,6 +902,47 @@ func newCmd(db *sqlx.DB) error {
 						Value: false,
 					},
 				},
+				Subcommands: []*cli.Command{
+					{
+						Name:      "migrate",
+						Usage:     "Apply pending migrations",
+						ArgsUsage: " ",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "status",
+								Usage: "show the status of migrations instead",
+								Value: false,
+							},
+						},
+						Action: func(cCtx *cli.Context) error {
+							if cCtx.Args().Present() {
+								return cli.ShowSubcommandHelp(cCtx)
+							}
+
+							if cCtx.Bool("status") {
+								out, err := tr.MigrationStatus()
+								if err != nil {
+									return err
+								}
+								fmt.Print(out)
+								return nil
+							}
+							applied, err := tr.Migrate()
+							if err != nil {
+								return err
+							}
+							for _, m := range applied {
+								fmt.Printf("Migration %d %#v applied\n", m.Version, m.Name)
+							}
+							version, err := tr.Version()
+							if err != nil {
+								return err
+							}
+							fmt.Printf("Database is up to date at version %d\n", version)
+							return nil
+						},
+					},
+				},
 				Action: func(cCtx *cli.Context) error {
 					if cCtx.Args().Present() {
 						return cli.ShowSubcommandHelp(cCtx)
@@ -269,8 +950,7 @@ func newCmd(db *sqlx.DB) error {
 
 					dbviews := cCtx.Bool("update-views")
 					if dbviews {
-						initDbViews(db)
-						return nil
+						return tr.UpdateViews()
 					}
 					c := exec.Command("sqlite3", "-box", dbFile)
 					c.Stdin = os.Stdin
@@ -288,520 +968,177 @@ func newCmd(db *sqlx.DB) error {
 	return nil
 }
 
-func initDb(db *sqlx.DB) error {
-	var exists bool
-	err := db.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type="table" AND name="log"`).Scan(&exists)
-	if err != nil {
-		return err
-	} else if exists {
-		return nil
-	}
-	_, err = db.Exec(`
-		CREATE TABLE log(
-			id INTEGER PRIMARY KEY,
-			name TEXT NOT NULL,
-			started INTEGER UNIQUE NOT NULL,
-			duration INTEGER NOT NULL DEFAULT 0,
-			current INTEGER UNIQUE DEFAULT 1 CHECK (current IN (1))
-		);
-
-		CREATE TRIGGER on_insert_started INSERT ON log
-		FOR EACH ROW
-		BEGIN
-			SELECT RAISE(ABORT, 'started must be latest')
-			WHERE NEW.started < (SELECT MAX(started + duration) FROM log);
-		END;
-	`)
-	if err != nil {
-		return err
-	}
-	return initDbViews(db)
-}
-
-func initDbViews(db *sqlx.DB) error {
-	_, err := db.Exec(`
-		DROP VIEW IF EXISTS latest;
-		CREATE VIEW latest AS
-		SELECT *
-		FROM log
-		ORDER BY started DESC
-		LIMIT 1;
-
-		DROP VIEW IF EXISTS log_pretty;
-		CREATE VIEW log_pretty AS
-		SELECT
-			id,
-			name,
-			date(started, 'unixepoch', 'localtime') started_date,
-			time(started, 'unixepoch', 'localtime') started_time,
-			duration,
-			time(duration, 'unixepoch') duration_pretty,
-			current,
-			datetime(started + duration, 'unixepoch', 'localtime') updated
-		FROM log;
-
-		DROP VIEW IF EXISTS log_daily;
-		CREATE VIEW log_daily AS
-		SELECT
-			started_date as date,
-			name,
-			time(SUM(duration), 'unixepoch') duration_pretty,
-			SUM(duration) duration
-		FROM log_pretty
-		GROUP BY started_date, name;
-
-		-- Drop deprecated views
-		DROP VIEW IF EXISTS current;
-	`)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-// Latest returns the latest activity if exists
-func Latest(db *sqlx.DB) (activity Activity, err error) {
-	err = db.Get(&activity, `SELECT * FROM latest`)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return Activity{}, fmt.Errorf("cannot get the latest activity: %v", err)
-	}
-	return activity, nil
-}
-
-// Start starts new activity
-func Start(db *sqlx.DB, name string, shift time.Duration) error {
-	name = strings.TrimSpace(name)
-	activity, err := Latest(db)
-	if err != nil {
-		return err
-	}
-	if activity.Active() && activity.Name == name {
-		return errors.New("Keep tracking existing activity")
-	}
-	_, err = UpdateIfExists(db, "", true)
-	if err != nil {
-		return err
-	}
-	_, err = db.NamedExec(`
-		INSERT INTO log (name, started, duration) VALUES (:name, strftime('%s', 'now') - :shiftSeconds, :shiftSeconds)
-	`, map[string]interface{}{
-		"name":         name,
-		"shiftSeconds": shift.Seconds(),
-	})
-	if err != nil {
-		return fmt.Errorf("cannot insert new activity into database: %v", err)
-	}
-	fmt.Printf("New activity %#v started\n", name)
-	return nil
-}
-
-// UpdateIfExists updates or finishes current activity if exists
-func UpdateIfExists(db *sqlx.DB, name string, finish bool) (bool, error) {
-	activity, err := Latest(db)
+// Select selects new activity using the menu, names are ranked by frecency
+func Select(tr *tracker.Tracker, menu, prompt string, forgetAfter int, hints bool) (string, error) {
+	suggestions, err := tr.Suggestions(forgetAfter)
 	if err != nil {
-		return false, err
+		return "", err
 	}
-	if activity.Expired() {
-		_, err := db.Exec(`UPDATE log SET current=NULL WHERE id = ?`, activity.ID)
-		if err != nil {
-			return false, err
+	// a hint is a part of the option, so keep options to get names back
+	names := map[string]string{}
+	options := make([]string, 0, len(suggestions))
+	for _, s := range suggestions {
+		option := s.Name
+		if hints {
+			option = fmt.Sprintf("%s  (%s)", s.Name, s.Hint())
 		}
-		return false, nil
-	} else if !activity.Active() {
-		return false, nil
-	}
-
-	name = strings.TrimSpace(name)
-	if name == "" {
-		name = activity.Name
+		names[option] = s.Name
+		options = append(options, option)
 	}
 
-	res, err := db.NamedExec(`
-		UPDATE log SET
-			duration=strftime('%s', 'now') - started,
-			current=(CASE WHEN :shouldBeFinished THEN NULL ELSE 1 END),
-			name=:name
-		WHERE id IN (SELECT id FROM latest)
-	`, map[string]interface{}{
-		"shouldBeFinished": finish,
-		"name":             name,
-		"id":               activity.ID,
-	})
+	selected, err := runMenu(menu, prompt, options)
 	if err != nil {
-		return false, err
+		return "", err
 	}
-	rowCnt, err := res.RowsAffected()
-	if err != nil {
-		return false, err
+	if name, ok := names[selected]; ok {
+		return name, nil
 	}
-	return rowCnt != 0, nil
+	return selected, nil
 }
 
-// Update updates or finishes current activity
-func Update(db *sqlx.DB, name string, finish bool) error {
-	updated, err := UpdateIfExists(db, name, finish)
-	if err != nil {
-		return err
-	}
-	if !updated {
-		return errors.New("no current activity")
+func rangeFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "from",
+			Usage: "the first date of the range (like 2006-01-02)",
+		},
+		&cli.StringFlag{
+			Name:  "to",
+			Usage: "the last date of the range (like 2006-01-02), today by default",
+		},
+		&cli.BoolFlag{
+			Name:  "yesterday",
+			Usage: "use yesterday as the range",
+		},
+		&cli.BoolFlag{
+			Name:  "week",
+			Usage: "use current week as the range",
+		},
+		&cli.BoolFlag{
+			Name:  "month",
+			Usage: "use current month as the range",
+		},
+		&cli.StringFlag{
+			Name:  "last",
+			Usage: "use last days as the range including today (like 7d, 2w)",
+		},
 	}
-	return nil
 }
 
-// Reject rejects current activity (deletes it)
-func Reject(db *sqlx.DB) error {
-	activity, err := Latest(db)
-	if err != nil {
-		return err
-	}
-	if activity.Active() {
-		_, err := db.Exec(`DELETE FROM log WHERE id = ?`, activity.ID)
-		if err != nil {
-			return err
+// parseRange returns the date range specified by rangeFlags, today by default
+func parseRange(cCtx *cli.Context, today time.Time) (tracker.DateRange, error) {
+	dates := tracker.DateRange{From: today, To: today}
+
+	shortcuts := 0
+	for _, name := range []string{"yesterday", "week", "month", "last"} {
+		if cCtx.IsSet(name) {
+			shortcuts++
 		}
 	}
-	return nil
-}
-
-// Show shows short information about current activity
-func Show(db *sqlx.DB, tpl string) error {
-	activity, err := Latest(db)
-	if err != nil {
-		return err
+	if shortcuts > 1 || shortcuts == 1 && (cCtx.IsSet("from") || cCtx.IsSet("to")) {
+		return dates, errors.New("only one of --from/--to, --yesterday, --week, --month, --last can be used")
 	}
-	txt, err := activity.Format(tpl)
-	if err != nil {
-		return err
-	}
-	fmt.Println(txt)
-	return nil
-}
 
-// Daemon updates the duration of current activity and runs the hook if specified
-func Daemon(
-	db *sqlx.DB,
-	intervalToShowBreakReminder time.Duration,
-	intervalToRepeatBreakReminder time.Duration,
-	hook string,
-) error {
-	var notified time.Time
-	var lastHook string
-	change := make(chan ChangeEvent)
-
-	go watchDbFile(change)
-
-	for {
-		activity, err := Latest(db)
+	switch {
+	case cCtx.Bool("yesterday"):
+		dates.From = today.AddDate(0, 0, -1)
+		dates.To = dates.From
+	case cCtx.Bool("week"):
+		dates.From = tracker.WeekStart(today)
+	case cCtx.Bool("month"):
+		dates.From = today.AddDate(0, 0, 1-today.Day())
+	case cCtx.IsSet("last"):
+		days, err := parseDays(cCtx.String("last"))
 		if err != nil {
-			return err
+			return dates, err
 		}
-		if hook != "" {
-			cmd, err := activity.Format(hook)
+		dates.From = today.AddDate(0, 0, 1-days)
+	case cCtx.IsSet("from") || cCtx.IsSet("to"):
+		var err error
+		if cCtx.IsSet("from") {
+			dates.From, err = parseDate(cCtx.String("from"))
 			if err != nil {
-				return fmt.Errorf("cannot render hook command: %v", err)
-			}
-			if lastHook != cmd {
-				lastHook = cmd
-				fmt.Printf("running hook command: %s\n", cmd)
-				err = exec.Command("sh", "-c", cmd).Run()
-				if err != nil {
-					return fmt.Errorf("cannot run hook command: %v", err)
-				}
+				return dates, err
 			}
 		}
-		if activity.Active() {
-			duration, err := activeDuration(db)
+		if cCtx.IsSet("to") {
+			dates.To, err = parseDate(cCtx.String("to"))
 			if err != nil {
-				return err
+				return dates, err
 			}
-			if duration > intervalToShowBreakReminder && time.Since(notified) > intervalToRepeatBreakReminder {
-				fmt.Printf("sending notification for %s\n", formatDuration(duration))
-				args := []string{
-					"Take a break!",
-					fmt.Sprintf("Active for %v already", formatDuration(duration)),
-				}
-				if duration.Seconds() > intervalToShowBreakReminder.Seconds()*1.2 {
-					args = append(args, "-u", "critical")
-				} else {
-					// default timeout is too quick, so set it to 5s
-					args = append(args, "-t", "5000")
-				}
-				err := exec.Command("notify-send", args...).Run()
-				if err != nil {
-					fmt.Printf("cannot send notification: %v\n", err)
-				}
-				notified = time.Now()
-			}
-		}
-
-		nextUpdate := activity.TimeSince().Truncate(time.Minute) + time.Minute - activity.TimeSince()
-		fmt.Printf("next update in %s\n", nextUpdate)
-
-		select {
-		case c := <-change:
-			fmt.Println("change", c)
-			if c.Error != nil {
-				return c.Error
-			}
-
-		case <-time.After(nextUpdate):
-			if activity.Active() && time.Since(activity.Updated()) > time.Minute {
-				fmt.Printf("updating time for %s\n", activity.Name)
-				_, err := UpdateIfExists(db, "", false)
-				if err != nil {
-					return err
-				}
-			}
-		}
-	}
-}
-
-type ChangeEvent struct {
-	Event fsnotify.Event
-	Error error
-}
-
-func watchDbFile(change chan ChangeEvent) error {
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return err
-	}
-	defer watcher.Close()
-
-	go func() {
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
-				change <- ChangeEvent{Event: event}
-
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return
-				}
-				change <- ChangeEvent{Error: err}
+			if !cCtx.IsSet("from") {
+				dates.From = dates.To
 			}
 		}
-	}()
-
-	if err := watcher.Add(dbFile); err != nil {
-		return err
-	}
-
-	<-make(chan struct{})
-	return nil
-}
-
-func activeDuration(db *sqlx.DB) (time.Duration, error) {
-	rows, err := db.Queryx(`SELECT * FROM log ORDER BY started DESC LIMIT 100`)
-	if err != nil {
-		return 0, err
-	}
-	defer rows.Close()
-
-	duration := time.Duration(0)
-	cur := Activity{}
-	prev := Activity{}
-	for rows.Next() {
-		err := rows.StructScan(&cur)
-		if err != nil {
-			return 0, err
-		}
-		if prev.ID == 0 && cur.Expired() {
-			break
-		} else if prev.Started().Sub(cur.Updated()) > intervalToExpire {
-			break
+		if dates.From.After(dates.To) {
+			return dates, errors.New("--from cannot be after --to")
 		}
-		duration += cur.Duration()
-		prev = cur
 	}
-	err = rows.Err()
-	if err != nil {
-		return 0, err
-	}
-	return duration, nil
+	return dates, nil
 }
 
-// Report reports about today's activities for now
-// TODO: add custom time range support
-func Report(db *sqlx.DB) (title, desc string, err error) {
-	duration, err := activeDuration(db)
-	if err != nil {
-		return "", "", err
-	}
-	if duration == time.Duration(0) {
-		latest, err := Latest(db)
-		if err != nil {
-			return "", "", err
-		}
-		title = fmt.Sprintf("Inactive for %v ", latest.FormatTimeSince())
-	} else {
-		title = fmt.Sprintf("Active for %v", formatDuration(duration))
-	}
-
-	rows, err := db.Queryx(`
-		SELECT name, duration
-		FROM log_daily
-		WHERE date = date('now')
-		GROUP BY name;
-	`)
+// parseDayStart parses the time of day like 04:00 as the offset from midnight
+func parseDayStart(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
 	if err != nil {
-		return "", "", err
-	}
-	defer rows.Close()
-
-	buf := bytes.Buffer{}
-	tabw := tabwriter.NewWriter(&buf, 0, 0, 1, ' ', tabwriter.TabIndent)
-	lineTpl := "%v\t %v\n"
-
-	duration = time.Duration(0)
-	a := Activity{}
-	count := 0
-	maxLength := 5 // length of "Total"
-	for rows.Next() {
-		err := rows.StructScan(&a)
-		if err != nil {
-			return "", "", err
-		}
-		count += 1
-		duration += a.Duration()
-		fmt.Fprintf(tabw, lineTpl, a.Name, formatDuration(a.Duration()))
-		if len(a.Name) > maxLength {
-			maxLength = len(a.Name)
-		}
-	}
-	if count == 0 {
-		return title, "", nil
+		return 0, fmt.Errorf("cannot parse day start %#v, expected format is 15:04", value)
 	}
-	if count > 1 {
-		fmt.Fprintf(tabw, lineTpl, strings.Repeat("-", maxLength), "-----")
-		fmt.Fprintf(tabw, lineTpl, "Total", formatDuration(duration))
-	}
-	tabw.Flush()
-	return title, buf.String(), nil
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
 }
 
-// Select selects new activity using rofi menu
-func Select(db *sqlx.DB) (string, error) {
-	var names []string
-	err := db.Select(&names, `SELECT DISTINCT name FROM log ORDER BY started DESC`)
-	if err != nil {
-		return "", err
-	}
-	cmd := exec.Command("rofi", "-dmenu")
-
-	cmdIn, err := cmd.StdinPipe()
-	if err != nil {
-		return "", err
-	}
-
-	cmdOut, err := cmd.StdoutPipe()
-	if err != nil {
-		return "", err
-	}
-
-	err = cmd.Start()
-	if err != nil {
-		return "", err
+func parsePeriod(value string) (string, error) {
+	if value != "day" && value != "week" {
+		return "", fmt.Errorf("unknown period %#v, use day or week", value)
 	}
-	for _, name := range names {
-		fmt.Fprintln(cmdIn, name)
-	}
-	cmdIn.Close()
-	selectedName, err := io.ReadAll(cmdOut)
-	if err != nil {
-		return "", err
-	}
-	err = cmd.Wait()
-	if err != nil {
-		return "", fmt.Errorf("cannot get selection from rofi: %v", err)
-	}
-	return string(selectedName), nil
-}
-
-func formatDuration(d time.Duration) string {
-	d = d.Truncate(time.Minute)
-	h := d / time.Hour
-	d -= h * time.Hour
-	m := d / time.Minute
-	return fmt.Sprintf("%02d:%02d", h, m)
-}
-
-// Activity represents a named activity
-type Activity struct {
-	ID          int64
-	Name        string
-	StartedInt  int64 `db:"started"`
-	DurationInt int64 `db:"duration"`
-	Current     sql.NullBool
+	return value, nil
 }
 
-func (a Activity) Format(tpl string) (string, error) {
-	var buf bytes.Buffer
-	t, err := template.New("tpl").Parse(tpl)
-	if err != nil {
-		return "", fmt.Errorf("failed to parse template: %v", err)
-	}
-	err = t.Execute(&buf, a)
+func parseDate(value string) (time.Time, error) {
+	date, err := time.ParseInLocation(tracker.DateLayout, value, time.Local)
 	if err != nil {
-		return "", fmt.Errorf("cannot format activity: %v", err)
+		return time.Time{}, fmt.Errorf("cannot parse date %#v, expected format is %v", value, tracker.DateLayout)
 	}
-	return strings.TrimSpace(buf.String()), nil
+	return date, nil
 }
 
-func (a Activity) Started() time.Time {
-	if a.StartedInt == 0 {
-		return time.Now()
+// parseTime parses a time like 15:04 for the given date or like 2006-01-02 15:04
+func parseTime(value string, date time.Time) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01-02 15:04", value, time.Local)
+	if err == nil {
+		return t, nil
 	}
-	return time.Unix(a.StartedInt, 0)
-}
-
-func (a Activity) TimeSince() time.Duration {
-	var duration time.Duration
-	if a.Active() {
-		duration = time.Since(a.Started())
-	} else {
-		duration = time.Since(a.Updated())
+	t, err = time.ParseInLocation("15:04", value, time.Local)
+	if err == nil {
+		y, m, d := date.Date()
+		return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, time.Local), nil
 	}
-	return duration.Truncate(time.Second)
+	return time.Time{}, fmt.Errorf("cannot parse time %#v, expected format is 15:04 or 2006-01-02 15:04", value)
 }
 
-func (a Activity) Duration() time.Duration {
-	var duration time.Duration
-	if a.Active() {
-		duration = time.Since(a.Started())
-	} else {
-		duration = time.Duration(a.DurationInt) * time.Second
+// parseNow parses the time for --now like 2006-01-02 15:04:05 or a time
+// accepted by parseTime
+func parseNow(value string) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", value, time.Local)
+	if err == nil {
+		return t, nil
 	}
-	return duration.Truncate(time.Second)
-}
-
-func (a Activity) FormatTimeSince() string {
-	return formatDuration(a.TimeSince())
+	return parseTime(value, time.Now())
 }
 
-func (a Activity) FormatLabel() string {
-	name := a.Name
-	if !a.Active() {
-		name = "OFF"
-	}
-	return fmt.Sprintf("%s %s", a.FormatTimeSince(), name)
-}
-
-func (a Activity) Updated() time.Time {
-	if a.StartedInt == 0 {
-		return time.Now()
+// parseDays parses a number of days like 7d or 2w
+func parseDays(value string) (int, error) {
+	var (
+		days int
+		unit string
+	)
+	_, err := fmt.Sscanf(value, "%d%s", &days, &unit)
+	if err == nil && days > 0 {
+		switch unit {
+		case "d":
+			return days, nil
+		case "w":
+			return days * 7, nil
+		}
 	}
-	return time.Unix(a.StartedInt+a.DurationInt, 0)
-}
-
-func (a Activity) Expired() bool {
-	return time.Since(a.Updated()) > intervalToExpire
-}
-
-func (a Activity) Active() bool {
-	return a.Current.Bool && !a.Expired()
+	return 0, fmt.Errorf("cannot parse %#v, expected number of days or weeks (like 7d, 2w)", value)
 }
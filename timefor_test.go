@@ -2,16 +2,21 @@ package main
 
 import (
 	"bytes"
+	"database/sql"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"path"
 	"strings"
 	"testing"
 	"text/template"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/jmoiron/sqlx"
+	"github.com/naspeh/timefor/tracker"
+	"github.com/urfave/cli/v2"
 	"gopkg.in/yaml.v2"
 )
 
@@ -25,65 +30,39 @@ func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
 
-func TestSchema(t *testing.T) {
-	db = sqlx.MustOpen("sqlite3", ":memory:")
-	defer db.Close()
-	initDb(db)
-
-	err := Start(db, "test", 0)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	var count int
-	_ = db.QueryRow(`SELECT count(*) FROM log`).Scan(&count)
-	if count != 1 {
-		t.Errorf("log table should have 1 row, but it has %v", count)
-	}
-
-	initDb(db)
-
-	_ = db.QueryRow(`SELECT count(*) FROM log`).Scan(&count)
-	if count != 1 {
-		t.Errorf("log table should have 1 row, but it has %v", count)
-	}
-
-	err = Start(db, "test", 0)
-	if diff := cmp.Diff(err.Error(), "Keep tracking existing activity"); diff != "" {
-		t.Errorf("expected different error: %v", diff)
-	}
-
-	_, err = db.Exec("INSERT INTO log (name, started) VALUES ('test', strftime('%s', 'now'))")
-	if err == nil {
-		t.Error("insert should not succeed")
-	}
-	if diff := cmp.Diff(err.Error(), "UNIQUE constraint failed: log.current"); diff != "" {
-		t.Errorf("expected different error: %v", diff)
-	}
-
-	_, err = db.Exec("INSERT INTO log (name, started, current) VALUES ('test', strftime('%s', 'now'), NULL)")
-	if err == nil {
-		t.Error("insert should not succeed")
-	}
-	if diff := cmp.Diff(err.Error(), "UNIQUE constraint failed: log.started"); diff != "" {
-		t.Errorf("expected different error: %v", diff)
-	}
-
-	err = Start(db, "test2", 0)
-	if diff := cmp.Diff(err.Error(), "cannot insert new activity into database: UNIQUE constraint failed: log.started"); diff != "" {
-		t.Errorf("expected different error: %v", diff)
-	}
+// testDB is a database file for TestCmd with a tracker to check the latest activity
+type testDB struct {
+	file string
+	tr   *tracker.Tracker
 }
 
 func TestCmd(t *testing.T) {
-	file, err := os.CreateTemp("", "logtest")
+	// cases use the main database unless they set another one by name,
+	// like cases with a fixed time, which would pollute the main one
+	dbs := map[string]testDB{}
+	openDB := func(name string) testDB {
+		if d, ok := dbs[name]; ok {
+			return d
+		}
+		file, err := os.CreateTemp("", "logtest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { os.Remove(file.Name()) })
+		db := sqlx.MustOpen("sqlite3", file.Name())
+		t.Cleanup(func() { db.Close() })
+		tr := tracker.New(tracker.DBStore(db), tracker.Options{})
+		tr.Init()
+		dbs[name] = testDB{file: file.Name(), tr: tr}
+		return dbs[name]
+	}
+
+	// an empty config directory, so defaults are used
+	configDir, err := os.MkdirTemp("", "logtest")
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer os.Remove(file.Name())
-
-	db = sqlx.MustOpen("sqlite3", file.Name())
-	defer db.Close()
+	defer os.RemoveAll(configDir)
 
 	data, err := os.ReadFile("testcmd.yaml")
 	if err != nil {
@@ -97,14 +76,16 @@ func TestCmd(t *testing.T) {
 		Output string
 		Error  string
 		Active bool
+		DB     string
 	}
 	err = yaml.Unmarshal(data, &cases)
 	if err != nil {
 		t.Fatal(err)
 	}
 	for _, c := range cases {
+		d := openDB(c.DB)
 		t.Run(c.Name, func(t *testing.T) {
-			line := fmt.Sprintf("DBFILE=%v ./timefor %v", file.Name(), c.Cmd)
+			line := fmt.Sprintf("DBFILE=%v CONFIGFILE= XDG_CONFIG_HOME=%v SOCKFILE= XDG_RUNTIME_DIR=%v ./timefor %v", d.file, configDir, configDir, c.Cmd)
 			cmd := exec.Command("sh", "-c", line)
 			out, err := cmd.CombinedOutput()
 			var exiterr *exec.ExitError
@@ -113,7 +94,7 @@ func TestCmd(t *testing.T) {
 			} else if errors.As(err, &exiterr) && exiterr.ExitCode() != c.Code {
 				t.Errorf("expected code %v got %v", c.Code, exiterr.ExitCode())
 			}
-			latest, err := Latest(db)
+			latest, err := d.tr.Latest()
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -129,3 +110,313 @@ func TestCmd(t *testing.T) {
 		})
 	}
 }
+
+func TestParseDays(t *testing.T) {
+	cases := map[string]int{"1d": 1, "7d": 7, "2w": 14}
+	for value, expected := range cases {
+		days, err := parseDays(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if days != expected {
+			t.Errorf("%v: expected %v got %v", value, expected, days)
+		}
+	}
+
+	for _, value := range []string{"", "7", "0d", "-1d", "1m", "1dd"} {
+		_, err := parseDays(value)
+		if err == nil {
+			t.Errorf("%v: expected error", value)
+		}
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	today := time.Date(2000, 1, 12, 0, 0, 0, 0, time.Local)
+	cases := map[string]string{
+		"":                                  "2000-01-12",
+		"--yesterday":                       "2000-01-11",
+		"--week":                            "2000-01-10..2000-01-12",
+		"--from 2000-01-01":                 "2000-01-01..2000-01-12",
+		"--to 2000-01-01":                   "2000-01-01",
+		"--from 2000-01-01 --to 2000-01-02": "2000-01-01..2000-01-02",
+	}
+	for args, expected := range cases {
+		var dates tracker.DateRange
+		app := &cli.App{
+			Flags: rangeFlags(),
+			Action: func(cCtx *cli.Context) (err error) {
+				dates, err = parseRange(cCtx, today)
+				return err
+			},
+		}
+		err := app.Run(append([]string{"timefor"}, strings.Fields(args)...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dates.String() != expected {
+			t.Errorf("%#v: expected %v got %v", args, expected, dates)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	db = sqlx.MustOpen("sqlite3", ":memory:")
+	defer db.Close()
+	tr := tracker.New(tracker.DBStore(db), tracker.Options{})
+	tr.Init()
+
+	err := tr.Start("@go", 0, " refactor parser ", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest, err := tr.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Note != "refactor parser" {
+		t.Errorf("expected different note: %#v", latest.Note)
+	}
+
+	name, err := Select(tr, `grep "@go"`, "start", 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "@go" {
+		t.Errorf("expected different name: %#v", name)
+	}
+
+	name, err = Select(tr, `cat > /dev/null; echo "{{.Prompt}}"`, "update", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "update" {
+		t.Errorf("expected different name: %#v", name)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	config, err := parseConfig("config.toml", []byte(`
+		break-interval = "50m"
+		hook = "echo {{.Name}}"
+		hints = false
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := defaultConfig()
+	expected.File = "config.toml"
+	expected.BreakInterval = Duration(50 * time.Minute)
+	expected.Hook = "echo {{.Name}}"
+	expected.Hints = false
+	if diff := cmp.Diff(config, expected, cmp.AllowUnexported(Config{})); diff != "" {
+		t.Errorf("expected different config: %v", diff)
+	}
+
+	config, err = parseConfig("config.yaml", []byte("repeat-interval: 5m\nmenu: fzf\nforget-after: 30\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = defaultConfig()
+	expected.File = "config.yaml"
+	expected.RepeatInterval = Duration(5 * time.Minute)
+	expected.Menu = "fzf"
+	expected.ForgetAfter = 30
+	if diff := cmp.Diff(config, expected, cmp.AllowUnexported(Config{})); diff != "" {
+		t.Errorf("expected different config: %v", diff)
+	}
+
+	for file, data := range map[string]string{
+		"config.toml": `menu = "fzf"` + "\n" + `hint = false`,
+		"config.yaml": "menu: fzf\nhint: false",
+		"config.json": `{"menu": "fzf"}`,
+	} {
+		_, err = parseConfig(file, []byte(data))
+		if err == nil {
+			t.Errorf("%v: expected error for %#v", file, data)
+		}
+	}
+
+	config, err = parseConfig("config.toml", []byte(`day-start = "04:00"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.dayStartSet || config.DayStart != "04:00" {
+		t.Errorf("expected day start to be set: %#v", config)
+	}
+
+	_, err = parseConfig("config.toml", []byte(`day-start = "4am"`))
+	if err == nil || !strings.Contains(err.Error(), "expected format is 15:04") {
+		t.Errorf("expected error for bad day start, got %v", err)
+	}
+
+	_, err = parseConfig("config.toml", []byte(`idle-threshold = "0s"`))
+	if err == nil || !strings.Contains(err.Error(), "idle-threshold must be positive") {
+		t.Errorf("expected error for zero idle threshold, got %v", err)
+	}
+
+	for _, data := range []string{`expire-interval = "0s"`, `expire-interval = "-5m"`} {
+		_, err = parseConfig("config.toml", []byte(data))
+		if err == nil || !strings.Contains(err.Error(), "expire-interval must be positive") {
+			t.Errorf("expected error for %v, got %v", data, err)
+		}
+	}
+}
+
+func TestIdleTime(t *testing.T) {
+	idle, err := DaemonOptions{IdleCmd: "echo 90000"}.idleTime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idle != 90*time.Second {
+		t.Errorf("expected different idle time: %v", idle)
+	}
+
+	file, err := os.CreateTemp("", "idletest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	err = os.Chtimes(file.Name(), time.Now(), time.Now().Add(-10*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idle, err = DaemonOptions{IdleFile: file.Name()}.idleTime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idle.Truncate(time.Minute) != 10*time.Minute {
+		t.Errorf("expected different idle time: %v", idle)
+	}
+}
+
+func TestTimer(t *testing.T) {
+	db = sqlx.MustOpen("sqlite3", ":memory:")
+	defer db.Close()
+	tr := tracker.New(tracker.DBStore(db), tracker.Options{})
+	tr.Init()
+
+	err := tr.Start("@go", 30*time.Minute, "", 25*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	activity, err := tr.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if activity.Planned() != 25*time.Minute || !strings.HasPrefix(activity.FormatCountdown(), "-05:0") {
+		t.Errorf("unexpected countdown %q for %#v", activity.FormatCountdown(), activity)
+	}
+
+	err = timeIsUp(tr, DaemonOptions{TimerAction: "break", TimerBreak: 5 * time.Minute}, activity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	finished, err := tr.Get(activity.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if finished.Active() || finished.Duration() != 25*time.Minute {
+		t.Errorf("expected finished activity at the planned end: %#v", finished)
+	}
+	latest, err := tr.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Name != "@break" || !latest.Active() || latest.Planned() != 5*time.Minute || latest.StartedInt != finished.Updated().Unix() {
+		t.Errorf("unexpected break: %#v", latest)
+	}
+	if (tracker.Activity{}).FormatCountdown() != "" {
+		t.Error("expected empty countdown without a plan")
+	}
+}
+
+func TestSocket(t *testing.T) {
+	db = sqlx.MustOpen("sqlite3", ":memory:")
+	defer db.Close()
+	// the same in-memory database for the socket goroutines
+	db.SetMaxOpenConns(1)
+	tr := tracker.New(tracker.DBStore(db), tracker.Options{})
+	tr.Init()
+
+	dir, err := os.MkdirTemp("", "socktest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sockFile = path.Join(dir, "timefor.sock")
+	defer func() { sockFile = "" }()
+
+	listener, err := listenSocket(tr, sockFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = listenSocket(tr, sockFile)
+	if err == nil || !strings.Contains(err.Error(), "daemon is already running") {
+		t.Errorf("expected error for the second daemon, got %v", err)
+	}
+
+	out, err := Call(tr, Request{Command: "start", Name: "@go", Planned: Duration(25 * time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != `New activity "@go" started for 00:25` {
+		t.Errorf("unexpected output: %q", out)
+	}
+	out, err = Call(tr, Request{Command: "show", Template: "{{.Name}} {{.FormatCountdown}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, "@go 2") {
+		t.Errorf("unexpected output: %q", out)
+	}
+	_, err = Call(tr, Request{Command: "start", Name: "@go"})
+	if err == nil || err.Error() != "Keep tracking existing activity" {
+		t.Errorf("expected error from daemon, got %v", err)
+	}
+	_, err = Call(tr, Request{Command: "stop"})
+	if err == nil || err.Error() != `unknown command "stop"` {
+		t.Errorf("expected unknown command, got %v", err)
+	}
+
+	listener.Close()
+	_, err = Call(tr, Request{Command: "finish"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest, err := tr.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Active() {
+		t.Errorf("expected finished activity without daemon: %#v", latest)
+	}
+}
+
+func TestStatusLine(t *testing.T) {
+	activity := tracker.Activity{Name: "@go", StartedInt: time.Now().Add(-30 * time.Minute).Unix(), DurationInt: 30 * 60, Current: sql.NullBool{Bool: true, Valid: true}, PlannedInt: 25 * 60}
+	cases := map[string]string{
+		"plain":  `@go`,
+		"i3bar":  `[{"name":"timefor","full_text":"@go","urgent":true}]`,
+		"waybar": `{"text":"@go","class":"overdue"}`,
+	}
+	for format, expected := range cases {
+		line, err := statusLine(activity, format, "{{.Name}}")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line != expected {
+			t.Errorf("expected different %v line: %v", format, line)
+		}
+	}
+
+	activity.Current = sql.NullBool{}
+	activity.Note = "parser"
+	line, err := statusLine(activity, "waybar", "{{.Name}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != `{"text":"@go","tooltip":"parser","class":"inactive"}` {
+		t.Errorf("expected different waybar line: %v", line)
+	}
+}
@@ -0,0 +1,191 @@
+package tracker
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Activity represents a named activity
+type Activity struct {
+	ID          int64
+	Name        string
+	StartedInt  int64 `db:"started"`
+	DurationInt int64 `db:"duration"`
+	Current     sql.NullBool
+	Note        string
+	PlannedInt  int64 `db:"planned"`
+	// Goals are goals for tags of the activity, they are loaded for templates only
+	Goals []GoalStatus `db:"-" json:"-"`
+	// expire is the expire interval of the tracker, the default if it's zero
+	expire time.Duration
+	// clock is the clock of the tracker, the system clock if it's nil
+	clock Clock
+}
+
+func (a Activity) Format(tpl string) (string, error) {
+	var buf bytes.Buffer
+	t, err := template.New("tpl").Parse(tpl)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %v", err)
+	}
+	err = t.Execute(&buf, a)
+	if err != nil {
+		return "", fmt.Errorf("cannot format activity: %v", err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// now returns the current time of the tracker clock
+func (a Activity) now() time.Time {
+	if a.clock == nil {
+		return time.Now()
+	}
+	return a.clock.Now()
+}
+
+func (a Activity) Started() time.Time {
+	if a.StartedInt == 0 {
+		return a.now()
+	}
+	return time.Unix(a.StartedInt, 0)
+}
+
+func (a Activity) TimeSince() time.Duration {
+	var duration time.Duration
+	if a.Active() {
+		duration = a.now().Sub(a.Started())
+	} else {
+		duration = a.now().Sub(a.Updated())
+	}
+	return duration.Truncate(time.Second)
+}
+
+func (a Activity) Duration() time.Duration {
+	var duration time.Duration
+	if a.Active() {
+		duration = a.now().Sub(a.Started())
+	} else {
+		duration = time.Duration(a.DurationInt) * time.Second
+	}
+	return duration.Truncate(time.Second)
+}
+
+func (a Activity) FormatTimeSince() string {
+	return FormatDuration(a.TimeSince())
+}
+
+func (a Activity) FormatLabel() string {
+	name := a.Name
+	if !a.Active() {
+		name = "OFF"
+	}
+	return fmt.Sprintf("%s %s", a.FormatTimeSince(), name)
+}
+
+func (a Activity) Updated() time.Time {
+	if a.StartedInt == 0 {
+		return a.now()
+	}
+	return time.Unix(a.StartedInt+a.DurationInt, 0)
+}
+
+func (a Activity) Expired() bool {
+	expire := a.expire
+	if expire == 0 {
+		expire = DefaultIntervalToExpire
+	}
+	return a.now().Sub(a.Updated()) > expire
+}
+
+// GoalProgress returns the progress of the first goal like "01:30/04:00",
+// daily goals go first, it's empty if there are no goals
+func (a Activity) GoalProgress() string {
+	if len(a.Goals) == 0 {
+		return ""
+	}
+	return a.Goals[0].String()
+}
+
+// Planned returns the planned duration, it's zero if there is no plan
+func (a Activity) Planned() time.Duration {
+	return time.Duration(a.PlannedInt) * time.Second
+}
+
+// Countdown returns the time left to the planned end, negative if it's passed
+func (a Activity) Countdown() time.Duration {
+	if a.PlannedInt == 0 {
+		return 0
+	}
+	return a.Started().Add(a.Planned()).Sub(a.now()).Truncate(time.Second)
+}
+
+// FormatCountdown returns the countdown like "24:59" or "-01:05" if the
+// planned end is passed, it's empty if there is no plan
+func (a Activity) FormatCountdown() string {
+	if a.PlannedInt == 0 {
+		return ""
+	}
+	countdown := a.Countdown()
+	sign := ""
+	if countdown < 0 {
+		sign = "-"
+		countdown = -countdown
+	}
+	m := countdown / time.Minute
+	countdown -= m * time.Minute
+	return fmt.Sprintf("%s%02d:%02d", sign, m, countdown/time.Second)
+}
+
+func (a Activity) Active() bool {
+	return a.Current.Bool && !a.Expired()
+}
+
+// DateRange represents an inclusive range of local dates
+type DateRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+func (r DateRange) FromDate() string {
+	return r.From.Format(DateLayout)
+}
+
+func (r DateRange) ToDate() string {
+	return r.To.Format(DateLayout)
+}
+
+func (r DateRange) String() string {
+	if r.From.Equal(r.To) {
+		return r.FromDate()
+	}
+	return fmt.Sprintf("%v..%v", r.FromDate(), r.ToDate())
+}
+
+// WeekStart returns the first day of the week, weeks start on Monday
+func WeekStart(day time.Time) time.Time {
+	weekday := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -weekday)
+}
+
+// trimLines removes trailing spaces from lines, like the padding added
+// by tabwriter when the last column is empty
+func trimLines(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FormatDuration formats the duration like "01:30", seconds are truncated
+func FormatDuration(d time.Duration) string {
+	d = d.Truncate(time.Minute)
+	h := d / time.Hour
+	d -= h * time.Hour
+	m := d / time.Minute
+	return fmt.Sprintf("%02d:%02d", h, m)
+}
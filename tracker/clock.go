@@ -0,0 +1,22 @@
+package tracker
+
+import "time"
+
+// Clock tells the current time, the system clock is used by default
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FixedClock is stopped at the given time, so durations, expiry and
+// reports are the same on every run
+type FixedClock time.Time
+
+func (c FixedClock) Now() time.Time {
+	return time.Time(c)
+}
@@ -0,0 +1,196 @@
+package tracker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ExportFormats are formats supported by Export
+var ExportFormats = []string{"json", "ics", "timeclock", "org"}
+
+// ExportItem is an activity for exporting
+type ExportItem struct {
+	ID       int64
+	Name     string
+	Started  time.Time
+	Duration time.Duration
+	Note     string
+}
+
+// Ended returns the end time of the activity
+func (e ExportItem) Ended() time.Time {
+	return e.Started.Add(e.Duration)
+}
+
+// Export exports activities for the date range in the given format
+func (t *Tracker) Export(dates DateRange, format string) (string, error) {
+	var activities []Activity
+	err := selectNamed(t.db, &activities, `
+		SELECT *
+		FROM log
+		WHERE id IN (SELECT id FROM `+logDays+` WHERE date BETWEEN :from AND :to)
+		ORDER BY started
+	`, map[string]interface{}{
+		"from":     dates.FromDate(),
+		"to":       dates.ToDate(),
+		"dayStart": t.dayStartSeconds(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	items := make([]ExportItem, 0, len(activities))
+	for _, a := range activities {
+		items = append(items, ExportItem{
+			ID:       a.ID,
+			Name:     a.Name,
+			Started:  time.Unix(a.StartedInt, 0),
+			Duration: time.Duration(a.DurationInt) * time.Second,
+			Note:     a.Note,
+		})
+	}
+
+	switch format {
+	case "json":
+		return exportJSON(items)
+	case "ics":
+		return exportICS(items, t.Now()), nil
+	case "timeclock":
+		return exportTimeclock(items), nil
+	case "org":
+		return exportOrg(items), nil
+	}
+	return "", fmt.Errorf("unknown format %#v", format)
+}
+
+func exportJSON(items []ExportItem) (string, error) {
+	type jsonExportItem struct {
+		ID      int64  `json:"id"`
+		Name    string `json:"name"`
+		Started string `json:"started"`
+		Ended   string `json:"ended"`
+		Seconds int64  `json:"seconds"`
+		Note    string `json:"note"`
+	}
+	out := make([]jsonExportItem, 0, len(items))
+	for _, item := range items {
+		out = append(out, jsonExportItem{
+			ID:      item.ID,
+			Name:    item.Name,
+			Started: item.Started.Format(time.RFC3339),
+			Ended:   item.Ended().Format(time.RFC3339),
+			Seconds: int64(item.Duration.Seconds()),
+			Note:    item.Note,
+		})
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("cannot encode activities: %v", err)
+	}
+	return string(data) + "\n", nil
+}
+
+// exportICS returns iCalendar with an event per activity, now is used
+// for DTSTAMP of events
+func exportICS(items []ExportItem, now time.Time) string {
+	layout := "20060102T150405Z"
+	buf := bytes.Buffer{}
+	line := func(format string, args ...interface{}) {
+		buf.WriteString(foldICSLine(fmt.Sprintf(format, args...)))
+		buf.WriteString("\r\n")
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//naspeh//timefor//EN")
+	for _, item := range items {
+		line("BEGIN:VEVENT")
+		line("UID:timefor-%d-%d", item.ID, item.Started.Unix())
+		line("DTSTAMP:%v", now.UTC().Format(layout))
+		line("DTSTART:%v", item.Started.UTC().Format(layout))
+		line("DTEND:%v", item.Ended().UTC().Format(layout))
+		line("SUMMARY:%v", escapeICSText(item.Name))
+		if item.Note != "" {
+			line("DESCRIPTION:%v", escapeICSText(item.Note))
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return buf.String()
+}
+
+func escapeICSText(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(text)
+}
+
+// foldICSLine splits lines longer than 75 octets as iCalendar requires,
+// without breaking UTF-8 characters
+func foldICSLine(line string) string {
+	buf := strings.Builder{}
+	size := 0
+	for _, r := range line {
+		if n := len(string(r)); size+n > 75 {
+			buf.WriteString("\r\n ")
+			size = 1
+		}
+		buf.WriteRune(r)
+		size += len(string(r))
+	}
+	return buf.String()
+}
+
+// exportTimeclock returns ledger timeclock entries, the first word of the
+// name is the account like "work:review" for "@work/review" and the rest
+// of the name with the note is the description, blank names are "unnamed"
+func exportTimeclock(items []ExportItem) string {
+	layout := "2006/01/02 15:04:05"
+	buf := bytes.Buffer{}
+	for _, item := range items {
+		account, desc := "", item.Note
+		if fields := strings.Fields(item.Name); len(fields) > 0 {
+			account = strings.ReplaceAll(strings.TrimLeft(fields[0], "@"), "/", ":")
+			desc = strings.TrimSpace(strings.Join(fields[1:], " ") + " " + item.Note)
+		}
+		if account == "" {
+			account = "unnamed"
+		}
+		if desc != "" {
+			account += "  " + desc
+		}
+		fmt.Fprintf(&buf, "i %v %v\n", item.Started.Format(layout), account)
+		fmt.Fprintf(&buf, "o %v\n", item.Ended().Format(layout))
+	}
+	return buf.String()
+}
+
+// exportOrg returns org-mode headings for activity names with CLOCK lines
+// in LOGBOOK drawers
+func exportOrg(items []ExportItem) string {
+	var names []string
+	clocks := map[string][]string{}
+	layout := "2006-01-02 Mon 15:04"
+	for _, item := range items {
+		if _, ok := clocks[item.Name]; !ok {
+			names = append(names, item.Name)
+		}
+		duration := item.Duration.Truncate(time.Minute)
+		clocks[item.Name] = append(clocks[item.Name], fmt.Sprintf(
+			"CLOCK: [%v]--[%v] => %2d:%02d",
+			item.Started.Format(layout), item.Ended().Format(layout),
+			int(duration.Hours()), int(duration.Minutes())%60,
+		))
+	}
+
+	buf := bytes.Buffer{}
+	for _, name := range names {
+		fmt.Fprintf(&buf, "* %v\n", name)
+		fmt.Fprintln(&buf, "  :LOGBOOK:")
+		for _, clock := range clocks[name] {
+			fmt.Fprintf(&buf, "  %v\n", clock)
+		}
+		fmt.Fprintln(&buf, "  :END:")
+	}
+	return buf.String()
+}
@@ -0,0 +1,190 @@
+package tracker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Goal is a daily or weekly target for a tag, or a limit if Max is true
+type Goal struct {
+	ID          int64
+	Tag         string
+	Period      string
+	Max         bool
+	DurationInt int64 `db:"duration"`
+}
+
+func (g Goal) Duration() time.Duration {
+	return time.Duration(g.DurationInt) * time.Second
+}
+
+// dates returns the day or the week of the goal for today
+func (g Goal) dates(today time.Time) DateRange {
+	if g.Period == "week" {
+		return DateRange{From: WeekStart(today), To: today}
+	}
+	return DateRange{From: today, To: today}
+}
+
+// Kind returns "limit" for Max goals and "target" for others
+func (g Goal) Kind() string {
+	if g.Max {
+		return "limit"
+	}
+	return "target"
+}
+
+// GoalStatus is a goal with time spent in the current period
+type GoalStatus struct {
+	Goal
+	Dates DateRange
+	Spent time.Duration
+}
+
+// Done returns true if a target is reached or a limit is exceeded
+func (g GoalStatus) Done() bool {
+	if g.Max {
+		return g.Spent > g.Duration()
+	}
+	return g.Spent >= g.Duration()
+}
+
+// Status returns "reached" or "exceeded" if the goal is done
+func (g GoalStatus) Status() string {
+	switch {
+	case !g.Done():
+		return ""
+	case g.Max:
+		return "exceeded"
+	}
+	return "reached"
+}
+
+// String returns the progress like "01:30/04:00"
+func (g GoalStatus) String() string {
+	return fmt.Sprintf("%v/%v", FormatDuration(g.Spent), FormatDuration(g.Duration()))
+}
+
+// SetGoal sets the goal for the tag and the period, a previous goal is replaced
+func (t *Tracker) SetGoal(tag, period string, max bool, duration time.Duration) (string, error) {
+	tag = strings.Trim(strings.TrimLeft(strings.TrimSpace(tag), "@+"), "/")
+	if tag == "" {
+		return "", errors.New("a tag cannot be empty")
+	}
+	_, err := t.db.Exec(`
+		INSERT INTO goals (tag, period, max, duration) VALUES (?, ?, ?, ?)
+		ON CONFLICT (tag, period) DO UPDATE SET max = excluded.max, duration = excluded.duration
+	`, tag, period, max, int64(duration.Seconds()))
+	if err != nil {
+		return "", fmt.Errorf("cannot set goal: %v", err)
+	}
+	kind := "Target"
+	if max {
+		kind = "Limit"
+	}
+	return fmt.Sprintf("%v for %#v set to %v per %v\n", kind, tag, FormatDuration(duration), period), nil
+}
+
+// DeleteGoal deletes the goal for the tag and the period
+func (t *Tracker) DeleteGoal(tag, period string) error {
+	tag = strings.Trim(strings.TrimLeft(strings.TrimSpace(tag), "@+"), "/")
+	res, err := t.db.Exec(`DELETE FROM goals WHERE tag = ? AND period = ?`, tag, period)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("no goal for %#v per %v", tag, period)
+	}
+	return nil
+}
+
+// Goals returns all goals with time spent in their current periods
+func (t *Tracker) Goals() ([]GoalStatus, error) {
+	var goals []Goal
+	err := sqlx.Select(t.db, &goals, `SELECT * FROM goals ORDER BY tag, period`)
+	if err != nil {
+		return nil, err
+	}
+	today := t.Today()
+	statuses := make([]GoalStatus, 0, len(goals))
+	for _, g := range goals {
+		dates := g.dates(today)
+		var spent int64
+		err = getNamed(t.db, &spent, `
+			SELECT COALESCE(SUM(l.duration), 0) FROM `+logDays+` l
+			WHERE l.date BETWEEN :from AND :to AND `+tagCondition, map[string]interface{}{
+			"from":     dates.FromDate(),
+			"to":       dates.ToDate(),
+			"tag":      g.Tag,
+			"dayStart": t.dayStartSeconds(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, GoalStatus{Goal: g, Dates: dates, Spent: time.Duration(spent) * time.Second})
+	}
+	return statuses, nil
+}
+
+// GoalsFor returns goals for tags of the activity including parent tags
+// like "work" for "work/review", daily goals go first
+func (t *Tracker) GoalsFor(activity Activity) ([]GoalStatus, error) {
+	goals, err := t.Goals()
+	if err != nil {
+		return nil, err
+	}
+	var matched []GoalStatus
+	for _, period := range []string{"day", "week"} {
+		for _, g := range goals {
+			if g.Period != period {
+				continue
+			}
+			for _, tag := range parseTags(activity.Name) {
+				if tag == g.Tag || strings.HasPrefix(tag, g.Tag+"/") {
+					matched = append(matched, g)
+					break
+				}
+			}
+		}
+	}
+	return matched, nil
+}
+
+// WithGoals returns the activity with its goals for templates
+func (t *Tracker) WithGoals(activity Activity) (Activity, error) {
+	goals, err := t.GoalsFor(activity)
+	if err != nil {
+		return activity, err
+	}
+	activity.Goals = goals
+	return activity, nil
+}
+
+// ListGoals returns goals with their progress
+func (t *Tracker) ListGoals() (string, error) {
+	goals, err := t.Goals()
+	if err != nil {
+		return "", err
+	}
+	if len(goals) == 0 {
+		return "No goals yet\n", nil
+	}
+
+	buf := bytes.Buffer{}
+	tabw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	lineTpl := "%v\t%v\t%v\t%v\t%v\t%v\n"
+	fmt.Fprintf(tabw, lineTpl, "Tag", "Per", "Kind", "Goal", "Spent", "Status")
+	for _, g := range goals {
+		fmt.Fprintf(tabw, lineTpl, g.Tag, g.Period, g.Kind(), FormatDuration(g.Duration()), FormatDuration(g.Spent), g.Status())
+	}
+	tabw.Flush()
+	return trimLines(buf.String()), nil
+}
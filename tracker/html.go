@@ -0,0 +1,307 @@
+package tracker
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"math"
+	"strings"
+	"time"
+)
+
+// htmlColors are colors of activities in charts, they are repeated if
+// there are more activities
+var htmlColors = []string{
+	"#4e79a7", "#f28e2b", "#e15759", "#76b7b2", "#59a14f",
+	"#edc948", "#b07aa1", "#ff9da7", "#9c755f", "#bab0ac",
+}
+
+// htmlOtherColor is used in the timeline for activities without a group
+// in the report, like tags filtered out
+const htmlOtherColor = "#dddddd"
+
+type htmlBar struct {
+	X, Y, Width, Height float64
+	Color               string
+	Title               string
+}
+
+type htmlLabel struct {
+	X, Y   float64
+	Text   string
+	Anchor string
+}
+
+type htmlLine struct {
+	X1, Y1, X2, Y2 float64
+}
+
+// htmlChart is an SVG chart with coordinates calculated in advance
+type htmlChart struct {
+	Width, Height float64
+	Bars          []htmlBar
+	Labels        []htmlLabel
+	Lines         []htmlLine
+}
+
+type htmlTotal struct {
+	Name     string
+	Duration string
+	Color    string
+	Width    float64
+	Share    float64
+}
+
+var htmlTpl = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #333; }
+table { border-collapse: collapse; }
+th, td { padding: 4px 12px; text-align: left; }
+.duration, .share { text-align: right; font-family: monospace; }
+svg text { font-size: 11px; fill: #666; }
+svg line { stroke: #eee; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Status}}</p>
+{{if .Totals}}
+<h2>Timeline</h2>
+{{template "chart" .Timeline}}
+<h2>Totals</h2>
+<table>
+{{range .Totals}}<tr>
+<td>{{.Name}}</td>
+<td class="duration">{{.Duration}}</td>
+<td><svg width="200" height="12"><rect width="{{.Width}}" height="12" fill="{{.Color}}"></rect></svg></td>
+<td class="share">{{printf "%.1f" .Share}}%</td>
+</tr>
+{{end}}<tr><th>Total</th><th class="duration">{{.Total}}</th><th></th><th></th></tr>
+</table>
+<h2>Daily</h2>
+{{template "chart" .Daily}}
+{{else}}
+<p>No activities</p>
+{{end}}
+</body>
+</html>
+{{define "chart"}}<svg width="{{.Width}}" height="{{.Height}}">
+{{range .Lines}}<line x1="{{.X1}}" y1="{{.Y1}}" x2="{{.X2}}" y2="{{.Y2}}"></line>
+{{end}}{{range .Bars}}<rect x="{{.X}}" y="{{.Y}}" width="{{.Width}}" height="{{.Height}}" fill="{{.Color}}"><title>{{.Title}}</title></rect>
+{{end}}{{range .Labels}}<text x="{{.X}}" y="{{.Y}}" text-anchor="{{.Anchor}}">{{.Text}}</text>
+{{end}}</svg>{{end}}
+`))
+
+// HTML returns a self-contained page with a timeline of activities per day,
+// totals per activity and a daily stacked bar chart, charts are inline SVG
+func (t *Tracker) HTML(opts ReportOptions) (string, error) {
+	opts.Timesheet = true
+	report, err := t.Report(opts)
+	if err != nil {
+		return "", err
+	}
+
+	var days []time.Time
+	for day := opts.Dates.From; !day.After(opts.Dates.To); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	colors := map[string]string{}
+	for i, item := range report.Items {
+		colors[item.Name] = htmlColors[i%len(htmlColors)]
+	}
+
+	timeline, err := t.htmlTimeline(opts, days, colors)
+	if err != nil {
+		return "", err
+	}
+
+	var totals []htmlTotal
+	var max time.Duration
+	for _, item := range report.Items {
+		if item.Duration > max {
+			max = item.Duration
+		}
+	}
+	for _, item := range report.Items {
+		total := htmlTotal{Name: item.Name, Duration: FormatDuration(item.Duration), Color: colors[item.Name]}
+		if max > 0 {
+			total.Width = round(200 * item.Duration.Seconds() / max.Seconds())
+		}
+		if report.Total > 0 {
+			total.Share = 100 * item.Duration.Seconds() / report.Total.Seconds()
+		}
+		totals = append(totals, total)
+	}
+
+	buf := bytes.Buffer{}
+	err = htmlTpl.Execute(&buf, map[string]interface{}{
+		"Title":    fmt.Sprintf("Report for %v", report.Dates),
+		"Status":   strings.TrimSpace(report.Status()),
+		"Timeline": timeline,
+		"Totals":   totals,
+		"Total":    FormatDuration(report.Total),
+		"Daily":    htmlDaily(report, days, colors),
+	})
+	if err != nil {
+		return "", fmt.Errorf("cannot render report: %v", err)
+	}
+	return buf.String(), nil
+}
+
+// htmlTimeline returns a chart with a row per day and a bar per activity,
+// bars are colored as groups of the report, an activity grouped by tags
+// has the color of its first tag
+func (t *Tracker) htmlTimeline(opts ReportOptions, days []time.Time, colors map[string]string) (htmlChart, error) {
+	const (
+		labelWidth = 90.0
+		hourWidth  = 36.0
+		rowHeight  = 22.0
+		barHeight  = 16.0
+		top        = 20.0
+	)
+	chart := htmlChart{Width: labelWidth + 24*hourWidth + 10, Height: top + float64(len(days))*rowHeight}
+	for h := 0; h <= 24; h += 3 {
+		x := labelWidth + float64(h)*hourWidth
+		chart.Lines = append(chart.Lines, htmlLine{X1: x, Y1: top - 5, X2: x, Y2: chart.Height})
+		hour := days[0].Add(t.opts.DayStart + time.Duration(h)*time.Hour)
+		chart.Labels = append(chart.Labels, htmlLabel{X: x, Y: top - 8, Text: hour.Format("15:04"), Anchor: "middle"})
+	}
+
+	where := `l.date BETWEEN :from AND :to`
+	if opts.Tag != "" {
+		where += ` AND ` + tagCondition
+	}
+	var parts []struct {
+		Name     string
+		Date     string
+		Started  int64
+		Duration int64
+	}
+	err := selectNamed(t.db, &parts, `
+		SELECT l.name, l.date, l.started, l.duration
+		FROM `+logDays+` l
+		WHERE `+where+`
+		ORDER BY l.started
+	`, map[string]interface{}{
+		"from":     opts.Dates.FromDate(),
+		"to":       opts.Dates.ToDate(),
+		"tag":      opts.Tag,
+		"dayStart": t.dayStartSeconds(),
+	})
+	if err != nil {
+		return chart, err
+	}
+
+	rows := map[string]int{}
+	for i, day := range days {
+		rows[day.Format(DateLayout)] = i
+		y := top + float64(i)*rowHeight
+		chart.Labels = append(chart.Labels, htmlLabel{X: 0, Y: y + barHeight - 3, Text: day.Format("Mon 2006-01-02"), Anchor: "start"})
+	}
+	for _, p := range parts {
+		row, ok := rows[p.Date]
+		if !ok {
+			continue
+		}
+		dayStart := days[row].Add(t.opts.DayStart)
+		started := time.Unix(p.Started, 0)
+		duration := time.Duration(p.Duration) * time.Second
+		color, ok := colors[p.Name]
+		if opts.ByTag {
+			color, ok = "", false
+			for _, tag := range parseTags(p.Name) {
+				if color, ok = colors[tag]; ok {
+					break
+				}
+			}
+			if !ok {
+				color, ok = colors["(no tag)"]
+			}
+		}
+		if !ok {
+			color = htmlOtherColor
+		}
+		chart.Bars = append(chart.Bars, htmlBar{
+			X:      round(labelWidth + started.Sub(dayStart).Hours()*hourWidth),
+			Y:      top + float64(row)*rowHeight,
+			Width:  round(math.Max(duration.Hours()*hourWidth, 1)),
+			Height: barHeight,
+			Color:  color,
+			Title: fmt.Sprintf(
+				"%v %v - %v (%v)",
+				p.Name, started.Format("15:04"), started.Add(duration).Format("15:04"), FormatDuration(duration),
+			),
+		})
+	}
+	return chart, nil
+}
+
+// htmlDaily returns a chart with a column per day stacked from durations
+// of activities on the day, the scale comes from the highest column as an
+// activity grouped by tags is stacked once per tag
+func htmlDaily(report ReportData, days []time.Time, colors map[string]string) htmlChart {
+	const (
+		left      = 40.0
+		colWidth  = 28.0
+		colGap    = 12.0
+		height    = 200.0
+		top       = 10.0
+		labelsGap = 16.0
+	)
+	var max time.Duration
+	for _, day := range days {
+		var stacked time.Duration
+		for _, item := range report.Items {
+			stacked += item.Days[day.Format(DateLayout)]
+		}
+		if stacked > max {
+			max = stacked
+		}
+	}
+	// the scale is in whole hours with up to 5 grid lines
+	hours := int(math.Ceil(max.Hours()))
+	if hours == 0 {
+		hours = 1
+	}
+	step := (hours + 4) / 5
+	hours = (hours + step - 1) / step * step
+	scale := height / float64(hours)
+
+	chart := htmlChart{Width: left + float64(len(days))*(colWidth+colGap), Height: top + height + labelsGap + 4}
+	for h := 0; h <= hours; h += step {
+		y := top + height - float64(h)*scale
+		chart.Lines = append(chart.Lines, htmlLine{X1: left - 4, Y1: y, X2: chart.Width, Y2: y})
+		chart.Labels = append(chart.Labels, htmlLabel{X: left - 8, Y: y + 4, Text: fmt.Sprintf("%dh", h), Anchor: "end"})
+	}
+	for i, day := range days {
+		x := left + float64(i)*(colWidth+colGap) + colGap/2
+		y := top + height
+		date := day.Format(DateLayout)
+		for _, item := range report.Items {
+			d := item.Days[date]
+			if d == 0 {
+				continue
+			}
+			h := d.Hours() * scale
+			y -= h
+			chart.Bars = append(chart.Bars, htmlBar{
+				X:      round(x),
+				Y:      round(y),
+				Width:  colWidth,
+				Height: round(h),
+				Color:  colors[item.Name],
+				Title:  fmt.Sprintf("%v %v (%v)", date, item.Name, FormatDuration(d)),
+			})
+		}
+		chart.Labels = append(chart.Labels, htmlLabel{X: x + colWidth/2, Y: top + height + labelsGap, Text: day.Format("01-02"), Anchor: "middle"})
+	}
+	return chart
+}
+
+func round(v float64) float64 {
+	return math.Round(v*10) / 10
+}
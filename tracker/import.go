@@ -0,0 +1,294 @@
+package tracker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ImportFormats are formats of other trackers supported by Import
+var ImportFormats = []string{"timewarrior", "toggl-csv", "timeclock"}
+
+// ImportEntry is an activity from another tracker
+type ImportEntry struct {
+	Name     string
+	Started  time.Time
+	Duration time.Duration
+	Note     string
+}
+
+// ParseImport parses entries of another tracker in the given format
+func ParseImport(format string, r io.Reader) ([]ImportEntry, error) {
+	switch format {
+	case "timewarrior":
+		return parseTimewarrior(r)
+	case "toggl-csv":
+		return parseTogglCSV(r)
+	case "timeclock":
+		return parseTimeclock(r)
+	}
+	return nil, fmt.Errorf("unknown format %#v, use %v", format, strings.Join(ImportFormats, ", "))
+}
+
+// importName makes an activity name from the main part like a project and
+// tags, so "@project +tag" is the result
+func importName(main string, tags []string) string {
+	clean := func(value string) string {
+		return strings.Join(strings.Fields(value), "-")
+	}
+	name := clean(main)
+	if name == "" {
+		return ""
+	}
+	if !strings.HasPrefix(name, "@") {
+		name = "@" + name
+	}
+	for _, tag := range tags {
+		if tag = strings.TrimLeft(clean(tag), "+"); tag != "" {
+			name += " +" + tag
+		}
+	}
+	return name
+}
+
+// parseTimewarrior parses the output of "timew export", the first tag is
+// used as the name and the rest as tags, not finished entries are skipped
+func parseTimewarrior(r io.Reader) ([]ImportEntry, error) {
+	var items []struct {
+		Start      string   `json:"start"`
+		End        string   `json:"end"`
+		Tags       []string `json:"tags"`
+		Annotation string   `json:"annotation"`
+	}
+	err := json.NewDecoder(r).Decode(&items)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse timewarrior export: %v", err)
+	}
+
+	layout := "20060102T150405Z"
+	var entries []ImportEntry
+	for i, item := range items {
+		if item.End == "" {
+			continue
+		}
+		started, err := time.Parse(layout, item.Start)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse entry %d: %v", i+1, err)
+		}
+		ended, err := time.Parse(layout, item.End)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse entry %d: %v", i+1, err)
+		}
+		var name string
+		if len(item.Tags) > 0 {
+			name = importName(item.Tags[0], item.Tags[1:])
+		}
+		entries = append(entries, ImportEntry{
+			Name:     name,
+			Started:  started.Local(),
+			Duration: ended.Sub(started),
+			Note:     item.Annotation,
+		})
+	}
+	return entries, nil
+}
+
+// parseTogglCSV parses a detailed report exported from Toggl Track, the
+// project is used as the name and the description as the note, the
+// description is the name if there is no project
+func parseTogglCSV(r io.Reader) ([]ImportEntry, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse toggl CSV: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]int{}
+	for i, column := range records[0] {
+		columns[strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))] = i
+	}
+	for _, column := range []string{"Description", "Start date", "Start time", "End date", "End time"} {
+		if _, ok := columns[column]; !ok {
+			return nil, fmt.Errorf("cannot parse toggl CSV: no %#v column", column)
+		}
+	}
+	get := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	layout := "2006-01-02 15:04:05"
+	var entries []ImportEntry
+	for i, record := range records[1:] {
+		started, err := time.ParseInLocation(layout, get(record, "Start date")+" "+get(record, "Start time"), time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse line %d: %v", i+2, err)
+		}
+		ended, err := time.ParseInLocation(layout, get(record, "End date")+" "+get(record, "End time"), time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse line %d: %v", i+2, err)
+		}
+		var tags []string
+		if value := get(record, "Tags"); value != "" {
+			tags = strings.Split(value, ",")
+		}
+		main, note := get(record, "Project"), get(record, "Description")
+		if main == "" {
+			main, note = note, ""
+		}
+		entries = append(entries, ImportEntry{
+			Name:     importName(main, tags),
+			Started:  started,
+			Duration: ended.Sub(started),
+			Note:     note,
+		})
+	}
+	return entries, nil
+}
+
+// parseTimeclock parses ledger timeclock format like
+//
+//	i 2024/01/02 10:00:00 work:review  description
+//	o 2024/01/02 10:45:00
+//
+// the account is used as the name with subaccounts as subtags, the
+// description is the note, a not finished entry is skipped
+func parseTimeclock(r io.Reader) ([]ImportEntry, error) {
+	parseTime := func(fields []string) (time.Time, error) {
+		value := fields[1] + " " + fields[2]
+		for _, layout := range []string{"2006/01/02 15:04:05", "2006/01/02 15:04"} {
+			t, err := time.ParseInLocation(layout, value, time.Local)
+			if err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("cannot parse time %#v", value)
+	}
+
+	var entries []ImportEntry
+	var current *ImportEntry
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.ContainsAny(line[:1], ";#*") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("cannot parse line %d: %#v", n, line)
+		}
+		at, err := parseTime(fields)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse line %d: %v", n, err)
+		}
+		switch fields[0] {
+		case "i":
+			if current != nil {
+				return nil, fmt.Errorf("cannot parse line %d: clock-in without clock-out", n)
+			}
+			// the account and the description are separated by two spaces or a tab
+			rest := strings.TrimSpace(line[strings.Index(line, fields[2])+len(fields[2]):])
+			account, desc := rest, ""
+			if i := strings.Index(rest, "\t"); i != -1 {
+				account, desc = rest[:i], rest[i+1:]
+			} else if i := strings.Index(rest, "  "); i != -1 {
+				account, desc = rest[:i], rest[i+2:]
+			}
+			current = &ImportEntry{
+				Name:    importName(strings.ReplaceAll(account, ":", "/"), nil),
+				Started: at,
+				Note:    strings.TrimSpace(desc),
+			}
+		case "o", "O":
+			if current == nil {
+				return nil, fmt.Errorf("cannot parse line %d: clock-out without clock-in", n)
+			}
+			current.Duration = at.Sub(current.Started)
+			entries = append(entries, *current)
+			current = nil
+		default:
+			return nil, fmt.Errorf("cannot parse line %d: unknown code %#v", n, fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read timeclock file: %v", err)
+	}
+	return entries, nil
+}
+
+// Import inserts entries as finished activities in one transaction, entries
+// overlapping with existing activities or with each other are skipped,
+// nothing is inserted if dryRun is true
+func (t *Tracker) Import(entries []ImportEntry, dryRun bool) (string, error) {
+	if len(entries) == 0 {
+		return "No activities to import\n", nil
+	}
+	tx, err := t.db.Beginx()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	buf := bytes.Buffer{}
+	tabw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	lineTpl := "%v\t%v\t%v\t%v\t%v\n"
+	fmt.Fprintf(tabw, lineTpl, "Started", "Duration", "Name", "Note", "Status")
+	j := journal{command: "import"}
+	var imported, skipped int
+	for _, e := range entries {
+		name := strings.TrimSpace(e.Name)
+		err := checkImportEntry(tx, name, e, t.Now())
+		if err == nil {
+			var id int64
+			id, err = insertFinished(tx, name, e.Started, e.Duration, e.Note)
+			if err == nil {
+				j.inserted(id)
+			}
+		}
+		status := "new"
+		if err != nil {
+			status = "skipped: " + err.Error()
+			skipped++
+		} else {
+			imported++
+		}
+		fmt.Fprintf(tabw, lineTpl, e.Started.Format("2006-01-02 15:04"), FormatDuration(e.Duration), name, e.Note, status)
+	}
+	tabw.Flush()
+
+	summary := "%d activities imported, %d skipped\n"
+	if dryRun {
+		summary = "%d activities would be imported, %d skipped\n"
+	} else if err := j.save(tx, t.Now()); err != nil {
+		return "", err
+	} else if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(&buf, summary, imported, skipped)
+	return trimLines(buf.String()), nil
+}
+
+func checkImportEntry(tx Tx, name string, e ImportEntry, now time.Time) error {
+	if name == "" {
+		return errors.New("activity name cannot be empty")
+	}
+	if e.Duration <= 0 {
+		return errors.New("a duration must be positive")
+	}
+	if e.Started.Add(e.Duration).After(now) {
+		return errors.New("activity cannot end in the future")
+	}
+	return checkOverlap(tx, 0, e.Started, e.Duration)
+}
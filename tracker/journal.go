@@ -0,0 +1,200 @@
+package tracker
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// change is a change of an activity, Before is nil for inserted activity
+// and After is nil for deleted one
+type change struct {
+	ID     int64     `json:"id"`
+	Before *Activity `json:"before"`
+	After  *Activity `json:"after"`
+}
+
+func (c change) String() string {
+	switch {
+	case c.Before == nil && c.After != nil:
+		return fmt.Sprintf("#%d %v (new)", c.ID, c.After.Name)
+	case c.After == nil && c.Before != nil:
+		return fmt.Sprintf("#%d %v (deleted)", c.ID, c.Before.Name)
+	case c.Before != nil && c.Before.Name != c.After.Name:
+		return fmt.Sprintf("#%d %v -> %v", c.ID, c.Before.Name, c.After.Name)
+	case c.Before != nil:
+		return fmt.Sprintf("#%d %v", c.ID, c.Before.Name)
+	}
+	return fmt.Sprintf("#%d", c.ID)
+}
+
+// journal collects changes of activities made by a command to record them
+// as an event, so the command can be undone
+type journal struct {
+	command string
+	changes []change
+}
+
+// before saves the state of the activity before it's changed or deleted
+func (j *journal) before(q sqlx.Queryer, id int64) error {
+	var activity Activity
+	err := sqlx.Get(q, &activity, `SELECT * FROM log WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("cannot get activity #%d for the journal: %v", id, err)
+	}
+	j.changes = append(j.changes, change{ID: id, Before: &activity})
+	return nil
+}
+
+// inserted marks the activity as inserted
+func (j *journal) inserted(id int64) {
+	j.changes = append(j.changes, change{ID: id})
+}
+
+// save records the event created at the time with the current state of
+// changed activities
+func (j *journal) save(e sqlx.Ext, created time.Time) error {
+	if len(j.changes) == 0 {
+		return nil
+	}
+	for i, c := range j.changes {
+		var activity Activity
+		err := sqlx.Get(e, &activity, `SELECT * FROM log WHERE id = ?`, c.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("cannot get activity #%d for the journal: %v", c.ID, err)
+		}
+		j.changes[i].After = &activity
+	}
+	data, err := json.Marshal(j.changes)
+	if err != nil {
+		return err
+	}
+	_, err = e.Exec(`
+		INSERT INTO events (created, command, changes) VALUES (?, ?, ?)
+	`, created.Unix(), j.command, string(data))
+	if err != nil {
+		return fmt.Errorf("cannot record event: %v", err)
+	}
+	return nil
+}
+
+// Event is a recorded command with changes of activities
+type Event struct {
+	ID      int64
+	Created int64
+	Command string
+	Changes string
+	Undone  bool
+}
+
+func (e Event) changes() ([]change, error) {
+	var changes []change
+	err := json.Unmarshal([]byte(e.Changes), &changes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse changes of event #%d: %v", e.ID, err)
+	}
+	return changes, nil
+}
+
+// History lists recent events, the latest one is the first
+func (t *Tracker) History(limit int) (string, error) {
+	var events []Event
+	err := sqlx.Select(t.db, &events, `SELECT * FROM events ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return "", err
+	}
+	if len(events) == 0 {
+		return "No events yet\n", nil
+	}
+
+	buf := bytes.Buffer{}
+	tabw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	lineTpl := "%v\t%v\t%v\t%v\t%v\n"
+	fmt.Fprintf(tabw, lineTpl, "ID", "Time", "Command", "Activities", "Status")
+	for _, e := range events {
+		changes, err := e.changes()
+		if err != nil {
+			return "", err
+		}
+		var names []string
+		for _, c := range changes {
+			names = append(names, c.String())
+		}
+		status := ""
+		if e.Undone {
+			status = "undone"
+		}
+		created := time.Unix(e.Created, 0).Format("2006-01-02 15:04")
+		fmt.Fprintf(tabw, lineTpl, e.ID, created, e.Command, strings.Join(names, ", "), status)
+	}
+	tabw.Flush()
+	return trimLines(buf.String()), nil
+}
+
+// Undo restores activities changed by the latest not undone event
+func (t *Tracker) Undo() (string, error) {
+	var event Event
+	err := sqlx.Get(t.db, &event, `SELECT * FROM events WHERE NOT undone ORDER BY id DESC LIMIT 1`)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errors.New("nothing to undo")
+	} else if err != nil {
+		return "", err
+	}
+	changes, err := event.changes()
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := t.db.Beginx()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	for i := len(changes) - 1; i >= 0; i-- {
+		c := changes[i]
+		switch {
+		case c.Before == nil:
+			_, err = tx.Exec(`DELETE FROM log WHERE id = ?`, c.ID)
+		case c.After == nil:
+			_, err = sqlx.NamedExec(tx, `
+				INSERT INTO log (id, name, started, duration, current, note, planned)
+				VALUES (:id, :name, :started, :duration, :current, :note, :planned)
+			`, c.Before)
+		default:
+			_, err = sqlx.NamedExec(tx, `
+				UPDATE log SET
+					name=:name, started=:started, duration=:duration,
+					current=:current, note=:note, planned=:planned
+				WHERE id = :id
+			`, c.Before)
+		}
+		if err != nil {
+			return "", fmt.Errorf("cannot undo %#v: %v", event.Command, err)
+		}
+		if c.Before != nil {
+			err = updateTags(tx, c.ID, c.Before.Name)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	_, err = tx.Exec(`UPDATE events SET undone = 1 WHERE id = ?`, event.ID)
+	if err != nil {
+		return "", err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Command %#v undone\n", event.Command), nil
+}
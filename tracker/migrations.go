@@ -0,0 +1,380 @@
+package tracker
+
+import (
+	"bytes"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Migration is a schema change, applied migrations are counted in
+// "PRAGMA user_version", so new migrations must be added to the end only
+type Migration struct {
+	Version int
+	Name    string
+	sql     string
+	// fn is called after sql for changes which cannot be done in SQL only
+	fn func(tx Tx) error
+}
+
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create log table",
+		// the table may exist already in databases created before migrations
+		sql: `
+			CREATE TABLE IF NOT EXISTS log(
+				id INTEGER PRIMARY KEY,
+				name TEXT NOT NULL,
+				started INTEGER UNIQUE NOT NULL,
+				duration INTEGER NOT NULL DEFAULT 0,
+				current INTEGER UNIQUE DEFAULT 1 CHECK (current IN (1))
+			);
+		`,
+	},
+	{
+		Version: 2,
+		Name:    "allow adding activities in the past",
+		sql: `
+			DROP TRIGGER IF EXISTS on_insert_started;
+			CREATE TRIGGER on_insert_started INSERT ON log
+			FOR EACH ROW WHEN NEW.current IS NOT NULL
+			BEGIN
+				SELECT RAISE(ABORT, 'started must be latest')
+				WHERE NEW.started < (SELECT MAX(started + duration) FROM log);
+			END;
+
+			DROP TRIGGER IF EXISTS on_insert_overlap;
+			CREATE TRIGGER on_insert_overlap INSERT ON log
+			FOR EACH ROW WHEN NEW.current IS NULL
+			BEGIN
+				SELECT RAISE(ABORT, 'activity overlaps with existing one')
+				WHERE EXISTS (
+					SELECT 1 FROM log
+					WHERE started < NEW.started + NEW.duration AND started + duration > NEW.started
+				);
+			END;
+		`,
+	},
+	{
+		Version: 3,
+		Name:    "add activity_tags table",
+		sql: `
+			CREATE TABLE activity_tags(
+				log_id INTEGER NOT NULL REFERENCES log(id),
+				tag TEXT NOT NULL,
+				PRIMARY KEY (log_id, tag)
+			);
+			CREATE INDEX activity_tags_tag ON activity_tags(tag);
+
+			CREATE TRIGGER on_delete_log DELETE ON log
+			FOR EACH ROW
+			BEGIN
+				DELETE FROM activity_tags WHERE log_id = OLD.id;
+			END;
+		`,
+		fn: func(tx Tx) error {
+			var activities []Activity
+			err := sqlx.Select(tx, &activities, `SELECT * FROM log`)
+			if err != nil {
+				return err
+			}
+			for _, a := range activities {
+				err := updateTags(tx, a.ID, a.Name)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 4,
+		Name:    "add note column",
+		sql:     `ALTER TABLE log ADD COLUMN note TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		Version: 5,
+		Name:    "add planned column",
+		sql:     `ALTER TABLE log ADD COLUMN planned INTEGER NOT NULL DEFAULT 0`,
+	},
+	{
+		Version: 6,
+		Name:    "add events table",
+		// changes are JSON with states of activities before and after a command
+		sql: `
+			CREATE TABLE events(
+				id INTEGER PRIMARY KEY,
+				created INTEGER NOT NULL,
+				command TEXT NOT NULL,
+				changes TEXT NOT NULL,
+				undone INTEGER NOT NULL DEFAULT 0
+			);
+		`,
+	},
+	{
+		Version: 7,
+		Name:    "add goals table",
+		sql: `
+			CREATE TABLE goals(
+				id INTEGER PRIMARY KEY,
+				tag TEXT NOT NULL,
+				period TEXT NOT NULL CHECK (period IN ('day', 'week')),
+				max INTEGER NOT NULL DEFAULT 0,
+				duration INTEGER NOT NULL CHECK (duration > 0),
+				UNIQUE (tag, period)
+			);
+		`,
+	},
+	{
+		Version: 8,
+		Name:    "add settings table",
+		// settings are used by views, they are stored by StoreDayStart
+		sql: `
+			CREATE TABLE settings(
+				key TEXT PRIMARY KEY,
+				value TEXT NOT NULL
+			);
+		`,
+	},
+	{
+		Version: 9,
+		Name:    "bound overlap check by started",
+		// activities don't overlap, so only the latest one started before
+		// the new one and ones started within it can overlap, both are
+		// looked up using the index on started instead of scanning the table
+		sql: `
+			DROP TRIGGER IF EXISTS on_insert_overlap;
+			CREATE TRIGGER on_insert_overlap INSERT ON log
+			FOR EACH ROW WHEN NEW.current IS NULL
+			BEGIN
+				SELECT RAISE(ABORT, 'activity overlaps with existing one')
+				WHERE EXISTS (
+					SELECT 1 FROM log
+					WHERE started >= NEW.started AND started < NEW.started + NEW.duration
+				) OR (
+					SELECT started + duration FROM log
+					WHERE started < NEW.started
+					ORDER BY started DESC
+					LIMIT 1
+				) > NEW.started;
+			END;
+		`,
+	},
+	{
+		Version: 10,
+		Name:    "check overlap with current activity",
+		// current activity lasts until now, but its duration is stored on
+		// updates only, so a finished activity cannot end after it's started,
+		// otherwise it'd be the latest one instead of current activity
+		sql: `
+			DROP TRIGGER IF EXISTS on_insert_overlap;
+			CREATE TRIGGER on_insert_overlap INSERT ON log
+			FOR EACH ROW WHEN NEW.current IS NULL
+			BEGIN
+				SELECT RAISE(ABORT, 'activity overlaps with existing one')
+				WHERE EXISTS (
+					SELECT 1 FROM log
+					WHERE started >= NEW.started AND started < NEW.started + NEW.duration
+				) OR (
+					SELECT started + duration FROM log
+					WHERE started < NEW.started
+					ORDER BY started DESC
+					LIMIT 1
+				) > NEW.started OR EXISTS (
+					SELECT 1 FROM log
+					WHERE current = 1 AND started < NEW.started + NEW.duration
+				);
+			END;
+		`,
+	},
+}
+
+// Init applies pending migrations
+func (t *Tracker) Init() error {
+	_, err := t.Migrate()
+	return err
+}
+
+// StoreDayStart stores the day start of the tracker for log_days and log_daily
+// views, which are used outside of the tracker, it's written only if it's changed
+func (t *Tracker) StoreDayStart() error {
+	seconds := t.dayStartSeconds()
+	var stored int64
+	err := sqlx.Get(t.db, &stored, `
+		SELECT COALESCE((SELECT CAST(value AS INTEGER) FROM settings WHERE key = 'day-start'), 0)
+	`)
+	if err != nil || stored == seconds {
+		return err
+	}
+	_, err = t.db.Exec(`
+		INSERT INTO settings (key, value) VALUES ('day-start', ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value
+	`, seconds)
+	if err != nil {
+		return fmt.Errorf("cannot store day start: %v", err)
+	}
+	return nil
+}
+
+// Version returns the version of the database schema
+func (t *Tracker) Version() (int, error) {
+	return dbVersion(t.db)
+}
+
+func dbVersion(q sqlx.Queryer) (version int, err error) {
+	err = sqlx.Get(q, &version, `PRAGMA user_version`)
+	if err != nil {
+		return 0, fmt.Errorf("cannot get database version: %v", err)
+	}
+	if version > len(migrations) {
+		return 0, fmt.Errorf("database version %d is newer than supported %d", version, len(migrations))
+	}
+	return version, nil
+}
+
+// Migrate applies pending migrations and updates views if needed
+func (t *Tracker) Migrate() (applied []Migration, err error) {
+	version, err := dbVersion(t.db)
+	if err != nil || version == len(migrations) {
+		return nil, err
+	}
+
+	for _, m := range migrations[version:] {
+		err := t.applyMigration(m)
+		if err != nil {
+			return applied, err
+		}
+		applied = append(applied, m)
+	}
+	return applied, initDbViews(t.db)
+}
+
+func (t *Tracker) applyMigration(m Migration) error {
+	tx, err := t.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// another process could apply it already
+	version, err := dbVersion(tx)
+	if err != nil {
+		return err
+	} else if version >= m.Version {
+		return nil
+	}
+
+	_, err = tx.Exec(m.sql)
+	if err != nil {
+		return fmt.Errorf("cannot apply migration %d %#v: %v", m.Version, m.Name, err)
+	}
+	if m.fn != nil {
+		err = m.fn(tx)
+		if err != nil {
+			return fmt.Errorf("cannot apply migration %d %#v: %v", m.Version, m.Name, err)
+		}
+	}
+	_, err = tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, m.Version))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MigrationStatus returns migrations with their status formatted as a table
+func (t *Tracker) MigrationStatus() (string, error) {
+	version, err := dbVersion(t.db)
+	if err != nil {
+		return "", err
+	}
+
+	buf := bytes.Buffer{}
+	tabw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	lineTpl := "%v\t%v\t%v\n"
+	fmt.Fprintf(tabw, lineTpl, "Version", "Status", "Name")
+	for _, m := range migrations {
+		status := "pending"
+		if m.Version <= version {
+			status = "applied"
+		}
+		fmt.Fprintf(tabw, lineTpl, m.Version, status, m.Name)
+	}
+	tabw.Flush()
+	return buf.String(), nil
+}
+
+// UpdateViews recreates views, it's done by Migrate already if a migration
+// is applied
+func (t *Tracker) UpdateViews() error {
+	return initDbViews(t.db)
+}
+
+func initDbViews(db sqlx.Execer) error {
+	_, err := db.Exec(`
+		DROP VIEW IF EXISTS latest;
+		CREATE VIEW latest AS
+		SELECT *
+		FROM log
+		ORDER BY started DESC
+		LIMIT 1;
+
+		DROP VIEW IF EXISTS log_pretty;
+		CREATE VIEW log_pretty AS
+		SELECT
+			id,
+			name,
+			date(started, 'unixepoch', 'localtime') started_date,
+			time(started, 'unixepoch', 'localtime') started_time,
+			duration,
+			time(duration, 'unixepoch') duration_pretty,
+			current,
+			datetime(started + duration, 'unixepoch', 'localtime') updated,
+			note,
+			date(started - (SELECT seconds FROM day_start), 'unixepoch', 'localtime') day
+		FROM log;
+
+		-- day_start is the offset of day boundaries from midnight in seconds
+		DROP VIEW IF EXISTS day_start;
+		CREATE VIEW day_start AS
+		SELECT COALESCE((SELECT CAST(value AS INTEGER) FROM settings WHERE key = 'day-start'), 0) seconds;
+
+		-- log_days splits activities crossing day boundaries into parts per day
+		DROP VIEW IF EXISTS log_days;
+		CREATE VIEW log_days AS
+		WITH RECURSIVE parts(id, name, note, date, started, ended) AS (
+			SELECT id, name, note, date(started - s.seconds, 'unixepoch', 'localtime'), started, started + duration
+			FROM log, day_start s
+			UNION ALL
+			SELECT id, name, note, date(date, '+1 day'), strftime('%s', date, '+1 day', 'utc') + s.seconds, ended
+			FROM parts, day_start s
+			WHERE ended > strftime('%s', date, '+1 day', 'utc') + s.seconds
+		)
+		SELECT
+			id,
+			name,
+			note,
+			date,
+			started,
+			MIN(ended, strftime('%s', date, '+1 day', 'utc') + s.seconds) - started duration
+		FROM parts, day_start s;
+
+		DROP VIEW IF EXISTS log_daily;
+		CREATE VIEW log_daily AS
+		SELECT
+			date,
+			name,
+			time(SUM(duration), 'unixepoch') duration_pretty,
+			SUM(duration) duration
+		FROM log_days
+		GROUP BY date, name;
+
+		-- Drop deprecated views
+		DROP VIEW IF EXISTS current;
+	`)
+	if err != nil {
+		return err
+	}
+	return nil
+}
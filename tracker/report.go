@@ -0,0 +1,426 @@
+package tracker
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ReportItem represents the total duration of an activity
+type ReportItem struct {
+	Name     string
+	Duration time.Duration
+	Notes    []string
+	// Days are durations per date like "2006-01-02", they are set for timesheets only
+	Days map[string]time.Duration
+}
+
+// ReportData represents activities for a date range with current status
+type ReportData struct {
+	Dates DateRange
+	// IsToday is true if the range is today only
+	IsToday bool
+	// Active is true if there is an active activity, then StatusDuration is
+	// the active duration, otherwise it's the inactive one
+	Active         bool
+	StatusDuration time.Duration
+	Items          []ReportItem
+	Total          time.Duration
+	// Verbose is true if notes are reported
+	Verbose bool
+	// Timesheet is true if durations per day are reported
+	Timesheet bool
+	// Days are totals per date like "2006-01-02", they are set for timesheets only
+	Days map[string]time.Duration
+}
+
+// ReportOptions specifies which activities are reported and how
+type ReportOptions struct {
+	Dates DateRange `json:"dates"`
+	// Tag filters activities by the tag including its subtags like "work/review" for "work"
+	Tag string `json:"tag,omitempty"`
+	// ByTag groups activities by tags instead of names, an activity with several
+	// tags is counted in each of them, so the total is less than the sum then
+	ByTag bool `json:"by_tag,omitempty"`
+	// Verbose adds notes of activities
+	Verbose bool `json:"verbose,omitempty"`
+	// Timesheet adds durations per day, so the text is a matrix of activities and days
+	Timesheet bool `json:"timesheet,omitempty"`
+}
+
+// tagCondition matches activities "l" with the tag ":tag" or its subtags
+const tagCondition = `EXISTS (
+	SELECT 1 FROM activity_tags t
+	WHERE t.log_id = l.id AND (t.tag = :tag OR substr(t.tag, 1, length(:tag) + 1) = :tag || '/')
+)`
+
+// logDays is like log_days view, but the day start is ":dayStart" parameter
+// in seconds instead of the setting stored in the database, so queries
+// follow the day start of the tracker, activities are bounded by ":from" and
+// ":to" dates before splitting, activities don't overlap, so only the latest
+// one started before the range can cross into it, both bounds use the index
+// on started, queries still filter parts by the date
+const logDays = `(
+	WITH RECURSIVE parts(id, name, note, date, started, ended) AS (
+		SELECT id, name, note, date(started - :dayStart, 'unixepoch', 'localtime'), started, started + duration
+		FROM log
+		WHERE started < strftime('%s', :to, '+1 day', 'utc') + :dayStart
+			AND started + duration > strftime('%s', :from, 'utc') + :dayStart
+			AND started >= COALESCE((
+				SELECT started FROM log
+				WHERE started <= strftime('%s', :from, 'utc') + :dayStart
+				ORDER BY started DESC
+				LIMIT 1
+			), 0)
+		UNION ALL
+		SELECT id, name, note, date(date, '+1 day'), strftime('%s', date, '+1 day', 'utc') + :dayStart, ended
+		FROM parts
+		WHERE ended > strftime('%s', date, '+1 day', 'utc') + :dayStart
+	)
+	SELECT
+		id,
+		name,
+		note,
+		date,
+		started,
+		MIN(ended, strftime('%s', date, '+1 day', 'utc') + :dayStart) - started duration
+	FROM parts
+)`
+
+// Report reports about activities for the given options
+func (t *Tracker) Report(opts ReportOptions) (ReportData, error) {
+	report := ReportData{Dates: opts.Dates, Verbose: opts.Verbose, Timesheet: opts.Timesheet}
+	today := t.Today()
+	report.IsToday = opts.Dates.From.Equal(today) && opts.Dates.To.Equal(today)
+
+	duration, err := t.ActiveDuration()
+	if err != nil {
+		return report, err
+	}
+	if duration == time.Duration(0) {
+		latest, err := t.Latest()
+		if err != nil {
+			return report, err
+		}
+		report.StatusDuration = latest.TimeSince()
+	} else {
+		report.Active = true
+		report.StatusDuration = duration
+	}
+
+	where := `l.date BETWEEN :from AND :to`
+	if opts.Tag != "" {
+		where += ` AND ` + tagCondition
+	}
+	from := logDays + ` l`
+	group := `l.name`
+	if opts.ByTag {
+		from = logDays + ` l LEFT JOIN activity_tags t ON t.log_id = l.id`
+		group = `COALESCE(t.tag, '(no tag)')`
+	}
+	query := `
+		SELECT ` + group + ` name, SUM(l.duration) duration
+		FROM ` + from + `
+		WHERE ` + where + `
+		GROUP BY ` + group + `
+	`
+	args := map[string]interface{}{
+		"from":     opts.Dates.FromDate(),
+		"to":       opts.Dates.ToDate(),
+		"tag":      opts.Tag,
+		"dayStart": t.dayStartSeconds(),
+	}
+
+	rows, err := sqlx.NamedQuery(t.db, query, args)
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+
+	a := Activity{}
+	for rows.Next() {
+		err := rows.StructScan(&a)
+		if err != nil {
+			return report, err
+		}
+		report.Items = append(report.Items, ReportItem{Name: a.Name, Duration: a.Duration()})
+	}
+	err = rows.Err()
+	if err != nil {
+		return report, err
+	}
+
+	if opts.Verbose {
+		query = `
+			SELECT ` + group + ` name, l.note
+			FROM ` + from + `
+			WHERE ` + where + ` AND l.note != ''
+			ORDER BY l.started
+		`
+		var notes []Activity
+		err = selectNamed(t.db, &notes, query, args)
+		if err != nil {
+			return report, err
+		}
+		for i := range report.Items {
+			item := &report.Items[i]
+			seen := map[string]bool{}
+			for _, a := range notes {
+				if a.Name == item.Name && !seen[a.Note] {
+					seen[a.Note] = true
+					item.Notes = append(item.Notes, a.Note)
+				}
+			}
+		}
+	}
+
+	if opts.Timesheet {
+		query = `
+			SELECT l.date, ` + group + ` name, SUM(l.duration) duration
+			FROM ` + from + `
+			WHERE ` + where + `
+			GROUP BY l.date, ` + group + `
+		`
+		var days []struct {
+			Date     string
+			Name     string
+			Duration int64
+		}
+		err = selectNamed(t.db, &days, query, args)
+		if err != nil {
+			return report, err
+		}
+		for i := range report.Items {
+			item := &report.Items[i]
+			item.Days = map[string]time.Duration{}
+			for _, d := range days {
+				if d.Name == item.Name {
+					item.Days[d.Date] = time.Duration(d.Duration) * time.Second
+				}
+			}
+		}
+
+		// totals are queried separately as an activity with several tags
+		// is counted in each of them
+		query = `
+			SELECT l.date, '' name, SUM(l.duration) duration
+			FROM ` + logDays + ` l
+			WHERE ` + where + `
+			GROUP BY l.date
+		`
+		days = nil
+		err = selectNamed(t.db, &days, query, args)
+		if err != nil {
+			return report, err
+		}
+		report.Days = map[string]time.Duration{}
+		for _, d := range days {
+			report.Days[d.Date] = time.Duration(d.Duration) * time.Second
+		}
+	}
+
+	var total int64
+	query = `SELECT COALESCE(SUM(l.duration), 0) FROM ` + logDays + ` l WHERE ` + where
+	err = getNamed(t.db, &total, query, args)
+	if err != nil {
+		return report, err
+	}
+	report.Total = time.Duration(total) * time.Second
+	return report, nil
+}
+
+// Status returns the current status like "Active for 00:10"
+func (r ReportData) Status() string {
+	if r.Active {
+		return fmt.Sprintf("Active for %v", FormatDuration(r.StatusDuration))
+	}
+	return fmt.Sprintf("Inactive for %v ", FormatDuration(r.StatusDuration))
+}
+
+// Title returns the current status for today or the date range otherwise
+func (r ReportData) Title() string {
+	if r.IsToday {
+		return r.Status()
+	}
+	return fmt.Sprintf("Report for %v", r.Dates)
+}
+
+// Text returns activities formatted as a table
+func (r ReportData) Text() string {
+	if len(r.Items) == 0 {
+		return ""
+	}
+	if r.Timesheet {
+		return r.timesheet()
+	}
+
+	buf := bytes.Buffer{}
+	tabw := tabwriter.NewWriter(&buf, 0, 0, 1, ' ', tabwriter.TabIndent)
+	lineTpl := "%v\t %v\n"
+	if r.Verbose {
+		lineTpl = "%v\t %v\t %v\n"
+	}
+	line := func(name, duration string, notes []string) {
+		if r.Verbose {
+			fmt.Fprintf(tabw, lineTpl, name, duration, strings.Join(notes, "; "))
+		} else {
+			fmt.Fprintf(tabw, lineTpl, name, duration)
+		}
+	}
+
+	maxLength := 5 // length of "Total"
+	for _, item := range r.Items {
+		line(item.Name, FormatDuration(item.Duration), item.Notes)
+		if len(item.Name) > maxLength {
+			maxLength = len(item.Name)
+		}
+	}
+	if len(r.Items) > 1 {
+		line(strings.Repeat("-", maxLength), "-----", nil)
+		line("Total", FormatDuration(r.Total), nil)
+	}
+	tabw.Flush()
+	return trimLines(buf.String())
+}
+
+// timesheet returns a matrix with activities as rows and days as columns,
+// the last row and the last column are totals
+func (r ReportData) timesheet() string {
+	var days []time.Time
+	for day := r.Dates.From; !day.After(r.Dates.To); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	cell := func(d time.Duration) string {
+		if d == 0 {
+			return ""
+		}
+		return FormatDuration(d)
+	}
+
+	buf := bytes.Buffer{}
+	tabw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	row := func(cells ...string) {
+		fmt.Fprintln(tabw, strings.Join(cells, "\t"))
+	}
+
+	header := []string{""}
+	for _, day := range days {
+		header = append(header, day.Format("Mon 01-02"))
+	}
+	row(append(header, "Total")...)
+
+	for _, item := range r.Items {
+		cells := []string{item.Name}
+		for _, day := range days {
+			cells = append(cells, cell(item.Days[day.Format(DateLayout)]))
+		}
+		row(append(cells, FormatDuration(item.Duration))...)
+	}
+
+	cells := []string{"Total"}
+	for _, day := range days {
+		cells = append(cells, cell(r.Days[day.Format(DateLayout)]))
+	}
+	row(append(cells, FormatDuration(r.Total))...)
+	tabw.Flush()
+	return trimLines(buf.String())
+}
+
+// Format returns the report in machine-readable format: json, csv or tsv
+func (r ReportData) Format(format string) (string, error) {
+	switch format {
+	case "json":
+		return r.JSON()
+	case "csv":
+		return r.CSV(',')
+	case "tsv":
+		return r.CSV('\t')
+	}
+	return "", fmt.Errorf("unknown format %#v", format)
+}
+
+type jsonReportItem struct {
+	Name     string   `json:"name"`
+	Seconds  int64    `json:"seconds"`
+	Duration string   `json:"duration"`
+	Notes    []string `json:"notes,omitempty"`
+}
+
+// JSON returns the report as JSON document
+func (r ReportData) JSON() (string, error) {
+	items := make([]jsonReportItem, 0, len(r.Items))
+	for _, item := range r.Items {
+		items = append(items, jsonReportItem{
+			Name:     item.Name,
+			Seconds:  int64(item.Duration.Seconds()),
+			Duration: FormatDuration(item.Duration),
+			Notes:    item.Notes,
+		})
+	}
+	data, err := json.MarshalIndent(struct {
+		From          string           `json:"from"`
+		To            string           `json:"to"`
+		Active        bool             `json:"active"`
+		Status        string           `json:"status"`
+		StatusSeconds int64            `json:"status_seconds"`
+		Activities    []jsonReportItem `json:"activities"`
+		TotalSeconds  int64            `json:"total_seconds"`
+		Total         string           `json:"total"`
+	}{
+		From:          r.Dates.FromDate(),
+		To:            r.Dates.ToDate(),
+		Active:        r.Active,
+		Status:        strings.TrimSpace(r.Status()),
+		StatusSeconds: int64(r.StatusDuration.Seconds()),
+		Activities:    items,
+		TotalSeconds:  int64(r.Total.Seconds()),
+		Total:         FormatDuration(r.Total),
+	}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("cannot encode report: %v", err)
+	}
+	return string(data), nil
+}
+
+// CSV returns the report as CSV with the given separator, each row has a type:
+// "activity" for activities, "total" for the total, "status" for the status,
+// verbose report has "notes" column as well
+func (r ReportData) CSV(comma rune) (string, error) {
+	row := func(kind, name string, d time.Duration, notes []string) []string {
+		record := []string{kind, name, strconv.FormatInt(int64(d.Seconds()), 10), FormatDuration(d)}
+		if r.Verbose {
+			record = append(record, strings.Join(notes, "; "))
+		}
+		return record
+	}
+	header := []string{"type", "name", "seconds", "duration"}
+	if r.Verbose {
+		header = append(header, "notes")
+	}
+	records := [][]string{header}
+	for _, item := range r.Items {
+		records = append(records, row("activity", item.Name, item.Duration, item.Notes))
+	}
+	records = append(records, row("total", "Total", r.Total, nil))
+	status := "inactive"
+	if r.Active {
+		status = "active"
+	}
+	records = append(records, row("status", status, r.StatusDuration, nil))
+
+	buf := bytes.Buffer{}
+	w := csv.NewWriter(&buf)
+	w.Comma = comma
+	err := w.WriteAll(records)
+	if err != nil {
+		return "", fmt.Errorf("cannot encode report: %v", err)
+	}
+	return buf.String(), nil
+}
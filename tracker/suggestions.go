@@ -0,0 +1,113 @@
+package tracker
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Suggestion is an activity name ranked by frecency
+type Suggestion struct {
+	Name     string
+	Score    float64
+	Today    time.Duration
+	LastUsed time.Time
+	// Days is the number of days since the day of the last use, it's zero for today
+	Days int
+}
+
+// Hint returns today's total if the name was used today or how long ago it was used
+func (s Suggestion) Hint() string {
+	if s.Today > 0 || s.Days <= 0 {
+		return fmt.Sprintf("today %v", FormatDuration(s.Today))
+	}
+	if s.Days == 1 {
+		return "yesterday"
+	}
+	return fmt.Sprintf("%dd ago", s.Days)
+}
+
+// daysSince returns the number of calendar days between the day of the time
+// and today, it's rounded as days around DST changes aren't 24 hours
+func (t *Tracker) daysSince(at time.Time) int {
+	y, m, d := at.Add(-t.opts.DayStart).Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	return int(math.Round(t.Today().Sub(day).Hours() / 24))
+}
+
+// frecencyWeight is higher for recent activities
+func frecencyWeight(age time.Duration) float64 {
+	switch {
+	case age < 4*time.Hour:
+		return 100
+	case age < 24*time.Hour:
+		return 80
+	case age < 3*24*time.Hour:
+		return 60
+	case age < 7*24*time.Hour:
+		return 40
+	case age < 30*24*time.Hour:
+		return 20
+	}
+	return 10
+}
+
+// Suggestions returns activity names ranked by frecency: each activity adds
+// a weight of its recency multiplied by its duration in hours plus one, so
+// both recent and long activities are higher, names unused for forgetAfter
+// days are skipped unless it's zero
+func (t *Tracker) Suggestions(forgetAfter int) ([]Suggestion, error) {
+	var since int64
+	if forgetAfter > 0 {
+		since = t.Today().AddDate(0, 0, 1-forgetAfter).Unix()
+	}
+	rows, err := t.db.Queryx(`SELECT * FROM log WHERE started >= ? ORDER BY started`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// the start of today including the day start offset
+	today := t.Today().Add(t.opts.DayStart)
+	byName := map[string]*Suggestion{}
+	var suggestions []*Suggestion
+	a := t.bind(Activity{})
+	for rows.Next() {
+		err := rows.StructScan(&a)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := byName[a.Name]
+		if !ok {
+			s = &Suggestion{Name: a.Name}
+			byName[a.Name] = s
+			suggestions = append(suggestions, s)
+		}
+		s.Score += frecencyWeight(t.Now().Sub(a.Updated())) * (1 + a.Duration().Hours())
+		s.LastUsed = a.Updated()
+		// only the part after the start of today is counted for activities
+		// started yesterday
+		if ended := a.Started().Add(a.Duration()); ended.After(today) {
+			started := a.Started()
+			if started.Before(today) {
+				started = today
+			}
+			s.Today += ended.Sub(started)
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Score > suggestions[j].Score
+	})
+	result := make([]Suggestion, 0, len(suggestions))
+	for _, s := range suggestions {
+		s.Days = t.daysSince(s.LastUsed)
+		result = append(result, *s)
+	}
+	return result, nil
+}
@@ -0,0 +1,44 @@
+package tracker
+
+import (
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// parseTags returns tags from the activity name: "@project" prefix and
+// "+tag" tokens, so "@work/review +client-x" has "work/review" and "client-x"
+func parseTags(name string) []string {
+	var tags []string
+	seen := map[string]bool{}
+	for i, token := range strings.Fields(name) {
+		var tag string
+		if i == 0 && strings.HasPrefix(token, "@") {
+			tag = token[1:]
+		} else if strings.HasPrefix(token, "+") {
+			tag = token[1:]
+		}
+		tag = strings.Trim(tag, "/")
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// updateTags replaces tags of the activity with ones parsed from the name
+func updateTags(db sqlx.Execer, id int64, name string) error {
+	_, err := db.Exec(`DELETE FROM activity_tags WHERE log_id = ?`, id)
+	if err != nil {
+		return err
+	}
+	for _, tag := range parseTags(name) {
+		_, err := db.Exec(`INSERT INTO activity_tags (log_id, tag) VALUES (?, ?)`, id, tag)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
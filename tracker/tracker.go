@@ -0,0 +1,676 @@
+// Package tracker keeps activities of timefor in a SQLite database, it's
+// the core of the command line tool and can be embedded into other tools:
+//
+//	db := sqlx.MustOpen("sqlite3", "timefor.db")
+//	t := tracker.New(tracker.DBStore(db), tracker.Options{DayStart: 4 * time.Hour})
+//	err := t.Init()
+//	...
+//	err = t.Start("@go", 0, "", 25*time.Minute)
+//	...
+//	report, err := t.Report(tracker.ReportOptions{Dates: tracker.DateRange{From: day, To: day}})
+package tracker
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// DefaultIntervalToExpire is the default for Options.IntervalToExpire
+	DefaultIntervalToExpire = 10 * time.Minute
+	// DateLayout is the layout of dates in views and reports
+	DateLayout = "2006-01-02"
+)
+
+// Options are settings of a tracker, zero values are defaults
+type Options struct {
+	// IntervalToExpire is an interval after which not updated activity is inactive
+	IntervalToExpire time.Duration
+	// DayStart is the offset of day boundaries from midnight, so activities
+	// before it are counted to the previous day
+	DayStart time.Duration
+	// Clock tells the current time, the system clock if it's nil
+	Clock Clock
+}
+
+// Store is a SQLite database with activities, DBStore wraps *sqlx.DB, so
+// an application can share its connection with the tracker
+type Store interface {
+	sqlx.Ext
+	Beginx() (Tx, error)
+}
+
+// Tx is a transaction of the store, *sqlx.Tx is a Tx
+type Tx interface {
+	sqlx.Ext
+	Commit() error
+	Rollback() error
+}
+
+// DBStore returns a store using the database
+func DBStore(db *sqlx.DB) Store {
+	return dbStore{db}
+}
+
+type dbStore struct {
+	*sqlx.DB
+}
+
+func (s dbStore) Beginx() (Tx, error) {
+	tx, err := s.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// Tracker starts, changes and reports activities kept in the store
+type Tracker struct {
+	db   Store
+	opts Options
+}
+
+// New returns a tracker for the store, Init must be called before using it
+func New(db Store, opts Options) *Tracker {
+	if opts.IntervalToExpire == 0 {
+		opts.IntervalToExpire = DefaultIntervalToExpire
+	}
+	if opts.Clock == nil {
+		opts.Clock = systemClock{}
+	}
+	return &Tracker{db: db, opts: opts}
+}
+
+// Now returns the current time of the clock
+func (t *Tracker) Now() time.Time {
+	return t.opts.Clock.Now()
+}
+
+// Today returns the current date, it's the previous one before the day start
+func (t *Tracker) Today() time.Time {
+	y, m, d := t.Now().Add(-t.opts.DayStart).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// bind sets settings of the tracker to the activity for its methods like Active
+func (t *Tracker) bind(a Activity) Activity {
+	a.expire = t.opts.IntervalToExpire
+	a.clock = t.opts.Clock
+	return a
+}
+
+// dayStartSeconds is the day start for ":dayStart" parameter of logDays
+func (t *Tracker) dayStartSeconds() int64 {
+	return int64(t.opts.DayStart.Seconds())
+}
+
+// getNamed is like sqlx.Get with named parameters
+func getNamed(e sqlx.Ext, dest interface{}, query string, arg interface{}) error {
+	query, args, err := e.BindNamed(query, arg)
+	if err != nil {
+		return err
+	}
+	return sqlx.Get(e, dest, query, args...)
+}
+
+// selectNamed is like sqlx.Select with named parameters
+func selectNamed(e sqlx.Ext, dest interface{}, query string, arg interface{}) error {
+	query, args, err := e.BindNamed(query, arg)
+	if err != nil {
+		return err
+	}
+	return sqlx.Select(e, dest, query, args...)
+}
+
+// Latest returns the latest activity if exists
+func (t *Tracker) Latest() (activity Activity, err error) {
+	err = sqlx.Get(t.db, &activity, `SELECT * FROM latest`)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return Activity{}, fmt.Errorf("cannot get the latest activity: %v", err)
+	}
+	return t.bind(activity), nil
+}
+
+// Start starts new activity, planned duration is used for a countdown if it's not zero
+func (t *Tracker) Start(name string, shift time.Duration, note string, planned time.Duration) error {
+	name = strings.TrimSpace(name)
+	activity, err := t.Latest()
+	if err != nil {
+		return err
+	}
+	if activity.Active() && activity.Name == name {
+		return errors.New("Keep tracking existing activity")
+	}
+	j := journal{command: "start"}
+	if activity.Current.Valid {
+		err = j.before(t.db, activity.ID)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = t.UpdateIfExists("", true)
+	if err != nil {
+		return err
+	}
+
+	tx, err := t.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := sqlx.NamedExec(tx, `
+		INSERT INTO log (name, started, duration, note, planned)
+		VALUES (:name, :now - :shiftSeconds, :shiftSeconds, :note, :planned)
+	`, map[string]interface{}{
+		"now":          t.Now().Unix(),
+		"name":         name,
+		"shiftSeconds": shift.Seconds(),
+		"note":         strings.TrimSpace(note),
+		"planned":      int64(planned.Seconds()),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot insert new activity into database: %v", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	err = updateTags(tx, id, name)
+	if err != nil {
+		return err
+	}
+	j.inserted(id)
+	err = j.save(tx, t.Now())
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateIfExists updates or finishes current activity if exists
+func (t *Tracker) UpdateIfExists(name string, finish bool) (bool, error) {
+	activity, err := t.Latest()
+	if err != nil {
+		return false, err
+	}
+	if activity.Expired() {
+		_, err := t.db.Exec(`UPDATE log SET current=NULL WHERE id = ?`, activity.ID)
+		if err != nil {
+			return false, err
+		}
+		return false, nil
+	} else if !activity.Active() {
+		return false, nil
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = activity.Name
+	}
+
+	res, err := sqlx.NamedExec(t.db, `
+		UPDATE log SET
+			duration=:now - started,
+			current=(CASE WHEN :shouldBeFinished THEN NULL ELSE 1 END),
+			name=:name
+		WHERE id IN (SELECT id FROM latest)
+	`, map[string]interface{}{
+		"now":              t.Now().Unix(),
+		"shouldBeFinished": finish,
+		"name":             name,
+		"id":               activity.ID,
+	})
+	if err != nil {
+		return false, err
+	}
+	rowCnt, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rowCnt != 0 && name != activity.Name {
+		err = updateTags(t.db, activity.ID, name)
+		if err != nil {
+			return false, err
+		}
+	}
+	return rowCnt != 0, nil
+}
+
+// Update updates or finishes current activity, only finishing and renaming
+// are recorded in the journal
+func (t *Tracker) Update(name string, finish bool) error {
+	activity, err := t.Latest()
+	if err != nil {
+		return err
+	}
+	j := journal{command: "update"}
+	if finish {
+		j.command = "finish"
+	}
+	name = strings.TrimSpace(name)
+	if activity.Active() && (finish || name != "" && name != activity.Name) {
+		err = j.before(t.db, activity.ID)
+		if err != nil {
+			return err
+		}
+	}
+	updated, err := t.UpdateIfExists(name, finish)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return errors.New("no current activity")
+	}
+	return j.save(t.db, t.Now())
+}
+
+// Note sets the note of current activity
+func (t *Tracker) Note(note string) error {
+	activity, err := t.Latest()
+	if err != nil {
+		return err
+	}
+	if !activity.Active() {
+		return errors.New("no current activity")
+	}
+	j := journal{command: "note"}
+	err = j.before(t.db, activity.ID)
+	if err != nil {
+		return err
+	}
+	_, err = t.db.Exec(`UPDATE log SET note = ? WHERE id = ?`, strings.TrimSpace(note), activity.ID)
+	if err != nil {
+		return err
+	}
+	return j.save(t.db, t.Now())
+}
+
+// FinishAt finishes the activity at the given time, the change is recorded
+// in the journal as the command like "idle" or "timer", so it can be undone
+func (t *Tracker) FinishAt(activity Activity, at time.Time, command string) error {
+	duration := at.Unix() - activity.StartedInt
+	if duration < 0 {
+		duration = 0
+	}
+	tx, err := t.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	j := journal{command: command}
+	err = j.before(tx, activity.ID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE log SET duration=?, current=NULL WHERE id = ?`, duration, activity.ID)
+	if err != nil {
+		return fmt.Errorf("cannot finish activity: %v", err)
+	}
+	err = j.save(tx, t.Now())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Resume resumes the latest activity if it's not active, the gap since it's
+// ended is counted to the activity if fill is true or recorded as a break
+func (t *Tracker) Resume(fill bool, breakName string) (string, error) {
+	activity, err := t.Latest()
+	if err != nil {
+		return "", err
+	}
+	if activity.ID == 0 {
+		return "", errors.New("no activity to resume")
+	} else if activity.Active() {
+		return "", errors.New("Keep tracking existing activity")
+	}
+	now := t.Now().Unix()
+	ended := activity.StartedInt + activity.DurationInt
+	gap := time.Duration(now-ended) * time.Second
+
+	tx, err := t.db.Beginx()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	j := journal{command: "resume"}
+	err = j.before(tx, activity.ID)
+	if err != nil {
+		return "", err
+	}
+	if fill {
+		_, err = tx.Exec(`UPDATE log SET duration=? - started, current=1 WHERE id = ?`, now, activity.ID)
+		if err != nil {
+			return "", fmt.Errorf("cannot resume activity: %v", err)
+		}
+	} else {
+		breakName = strings.TrimSpace(breakName)
+		_, err = tx.Exec(`UPDATE log SET current=NULL WHERE id = ?`, activity.ID)
+		if err != nil {
+			return "", err
+		}
+		insert := func(name, query string, args ...interface{}) error {
+			res, err := tx.Exec(query, args...)
+			if err != nil {
+				return fmt.Errorf("cannot resume activity: %v", err)
+			}
+			id, err := res.LastInsertId()
+			if err != nil {
+				return err
+			}
+			j.inserted(id)
+			return updateTags(tx, id, name)
+		}
+		if gap > 0 {
+			err = insert(breakName, `
+				INSERT INTO log (name, started, duration, current) VALUES (?, ?, ?, NULL)
+			`, breakName, ended, now-ended)
+			if err != nil {
+				return "", err
+			}
+		}
+		err = insert(activity.Name, `
+			INSERT INTO log (name, started, note) VALUES (?, ?, ?)
+		`, activity.Name, now, activity.Note)
+		if err != nil {
+			return "", err
+		}
+	}
+	err = j.save(tx, t.Now())
+	if err != nil {
+		return "", err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return "", err
+	}
+	if fill {
+		return fmt.Sprintf("Activity %#v resumed, the gap of %v is counted to it\n", activity.Name, FormatDuration(gap)), nil
+	}
+	return fmt.Sprintf("Activity %#v resumed after %v of %#v\n", activity.Name, FormatDuration(gap), breakName), nil
+}
+
+// Reject rejects current activity (deletes it)
+func (t *Tracker) Reject() error {
+	activity, err := t.Latest()
+	if err != nil {
+		return err
+	}
+	if activity.Active() {
+		j := journal{command: "reject"}
+		err := j.before(t.db, activity.ID)
+		if err != nil {
+			return err
+		}
+		_, err = t.db.Exec(`DELETE FROM log WHERE id = ?`, activity.ID)
+		if err != nil {
+			return err
+		}
+		return j.save(t.db, t.Now())
+	}
+	return nil
+}
+
+// Add adds finished activity in the past if there is no overlap
+func (t *Tracker) Add(name string, started time.Time, duration time.Duration, note string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("activity name cannot be empty")
+	}
+	if started.Add(duration).After(t.Now()) {
+		return "", errors.New("activity cannot end in the future")
+	}
+
+	tx, err := t.db.Beginx()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	err = checkOverlap(tx, 0, started, duration)
+	if err != nil {
+		return "", err
+	}
+	id, err := insertFinished(tx, name, started, duration, note)
+	if err != nil {
+		return "", err
+	}
+	j := journal{command: "add"}
+	j.inserted(id)
+	err = j.save(tx, t.Now())
+	if err != nil {
+		return "", err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(
+		"Activity %#v added (%v - %v)\n",
+		name, started.Format("15:04"), started.Add(duration).Format("15:04"),
+	), nil
+}
+
+// insertFinished inserts finished activity with its tags, overlaps must be
+// checked before
+func insertFinished(tx Tx, name string, started time.Time, duration time.Duration, note string) (int64, error) {
+	res, err := sqlx.NamedExec(tx, `
+		INSERT INTO log (name, started, duration, current, note)
+		VALUES (:name, :started, :duration, NULL, :note)
+	`, map[string]interface{}{
+		"name":     name,
+		"started":  started.Unix(),
+		"duration": int64(duration.Seconds()),
+		"note":     strings.TrimSpace(note),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("cannot insert activity into database: %v", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return id, updateTags(tx, id, name)
+}
+
+// Get returns the activity by id
+func (t *Tracker) Get(id int64) (activity Activity, err error) {
+	err = sqlx.Get(t.db, &activity, `SELECT * FROM log WHERE id = ?`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Activity{}, fmt.Errorf("activity #%d not found", id)
+	} else if err != nil {
+		return Activity{}, fmt.Errorf("cannot get activity #%d: %v", id, err)
+	}
+	return t.bind(activity), nil
+}
+
+// List returns activities for the given date range formatted as a table
+func (t *Tracker) List(dates DateRange) (string, error) {
+	var activities []Activity
+	err := selectNamed(t.db, &activities, `
+		SELECT *
+		FROM log
+		WHERE id IN (SELECT id FROM `+logDays+` WHERE date BETWEEN :from AND :to)
+		ORDER BY started
+	`, map[string]interface{}{
+		"from":     dates.FromDate(),
+		"to":       dates.ToDate(),
+		"dayStart": t.dayStartSeconds(),
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(activities) == 0 {
+		return fmt.Sprintf("No activities for %v\n", dates), nil
+	}
+
+	buf := bytes.Buffer{}
+	tabw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	lineTpl := "%v\t%v\t%v\t%v\t%v\n"
+	fmt.Fprintf(tabw, lineTpl, "ID", "Started", "Duration", "Name", "Note")
+	for _, a := range activities {
+		a = t.bind(a)
+		name := a.Name
+		if a.Active() {
+			name += " (active)"
+		}
+		fmt.Fprintf(tabw, lineTpl, a.ID, a.Started().Format("2006-01-02 15:04"), FormatDuration(a.Duration()), name, a.Note)
+	}
+	tabw.Flush()
+	return trimLines(buf.String()), nil
+}
+
+// Edit changes the name, the start time or the duration of the activity,
+// empty values are left unchanged
+func (t *Tracker) Edit(activity Activity, name string, started time.Time, duration time.Duration) (string, error) {
+	if duration != 0 && activity.Active() {
+		return "", errors.New("cannot change the duration of current activity, finish it first")
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = activity.Name
+	}
+	if started.IsZero() {
+		started = activity.Started()
+	}
+	if duration == 0 {
+		if activity.Active() {
+			duration = t.Now().Sub(started)
+		} else {
+			duration = activity.Duration()
+		}
+	}
+	if started.Add(duration).After(t.Now()) {
+		return "", errors.New("activity cannot end in the future")
+	}
+
+	tx, err := t.db.Beginx()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	err = checkOverlap(tx, activity.ID, started, duration)
+	if err != nil {
+		return "", err
+	}
+	j := journal{command: "edit"}
+	err = j.before(tx, activity.ID)
+	if err != nil {
+		return "", err
+	}
+	_, err = sqlx.NamedExec(tx, `
+		UPDATE log SET name=:name, started=:started, duration=:duration WHERE id=:id
+	`, map[string]interface{}{
+		"id":       activity.ID,
+		"name":     name,
+		"started":  started.Unix(),
+		"duration": int64(duration.Seconds()),
+	})
+	if err != nil {
+		return "", fmt.Errorf("cannot update activity #%d: %v", activity.ID, err)
+	}
+	err = updateTags(tx, activity.ID, name)
+	if err != nil {
+		return "", err
+	}
+	err = j.save(tx, t.Now())
+	if err != nil {
+		return "", err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Activity #%d %#v updated\n", activity.ID, name), nil
+}
+
+// checkOverlap returns an error if the given interval overlaps with
+// any activity except the one with the given id
+func checkOverlap(q sqlx.Queryer, id int64, started time.Time, duration time.Duration) error {
+	// activities don't overlap, so the scan starts from the latest one
+	// started before, it's bounded by the index on started
+	var other Activity
+	err := sqlx.Get(q, &other, `
+		SELECT *
+		FROM log
+		WHERE id != ? AND started < ? AND started + duration > ? AND started >= COALESCE((
+			SELECT started FROM log
+			WHERE id != ? AND started <= ?
+			ORDER BY started DESC
+			LIMIT 1
+		), 0)
+		ORDER BY started
+		LIMIT 1
+	`, id, started.Add(duration).Unix(), started.Unix(), id, started.Unix())
+	if err == nil {
+		return fmt.Errorf(
+			"activity overlaps with #%d %#v (%v - %v)",
+			other.ID, other.Name, other.Started().Format("15:04"), other.Updated().Format("15:04"),
+		)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	// current activity lasts until now, not until its last update
+	err = sqlx.Get(q, &other, `
+		SELECT * FROM log WHERE current = 1 AND id != ? AND started < ?
+	`, id, started.Add(duration).Unix())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return fmt.Errorf(
+		"activity overlaps with current #%d %#v (%v - now)",
+		other.ID, other.Name, other.Started().Format("15:04"),
+	)
+}
+
+// ActiveDuration returns how long activities are tracked without breaks
+// longer than the expire interval, it's zero if there is no active activity
+func (t *Tracker) ActiveDuration() (time.Duration, error) {
+	rows, err := t.db.Queryx(`SELECT * FROM log ORDER BY started DESC LIMIT 100`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	duration := time.Duration(0)
+	cur := t.bind(Activity{})
+	prev := Activity{}
+	for rows.Next() {
+		err := rows.StructScan(&cur)
+		if err != nil {
+			return 0, err
+		}
+		if prev.ID == 0 && cur.Expired() {
+			break
+		} else if prev.Started().Sub(cur.Updated()) > t.opts.IntervalToExpire {
+			break
+		}
+		duration += cur.Duration()
+		prev = cur
+	}
+	err = rows.Err()
+	if err != nil {
+		return 0, err
+	}
+	return duration, nil
+}
@@ -0,0 +1,1042 @@
+package tracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestSchema(t *testing.T) {
+	db := sqlx.MustOpen("sqlite3", ":memory:")
+	defer db.Close()
+	tr := New(DBStore(db), Options{})
+	tr.Init()
+
+	err := tr.Start("test", 0, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var count int
+	_ = db.QueryRow(`SELECT count(*) FROM log`).Scan(&count)
+	if count != 1 {
+		t.Errorf("log table should have 1 row, but it has %v", count)
+	}
+
+	tr.Init()
+
+	_ = db.QueryRow(`SELECT count(*) FROM log`).Scan(&count)
+	if count != 1 {
+		t.Errorf("log table should have 1 row, but it has %v", count)
+	}
+
+	err = tr.Start("test", 0, "", 0)
+	if diff := cmp.Diff(err.Error(), "Keep tracking existing activity"); diff != "" {
+		t.Errorf("expected different error: %v", diff)
+	}
+
+	_, err = db.Exec("INSERT INTO log (name, started) VALUES ('test', strftime('%s', 'now'))")
+	if err == nil {
+		t.Error("insert should not succeed")
+	}
+	if diff := cmp.Diff(err.Error(), "UNIQUE constraint failed: log.current"); diff != "" {
+		t.Errorf("expected different error: %v", diff)
+	}
+
+	_, err = db.Exec("INSERT INTO log (name, started, current) VALUES ('test', strftime('%s', 'now'), NULL)")
+	if err == nil {
+		t.Error("insert should not succeed")
+	}
+	if diff := cmp.Diff(err.Error(), "UNIQUE constraint failed: log.started"); diff != "" {
+		t.Errorf("expected different error: %v", diff)
+	}
+
+	err = tr.Start("test2", 0, "", 0)
+	if diff := cmp.Diff(err.Error(), "cannot insert new activity into database: UNIQUE constraint failed: log.started"); diff != "" {
+		t.Errorf("expected different error: %v", diff)
+	}
+
+	_, err = db.Exec("INSERT INTO log (name, started, duration, current) VALUES ('test', strftime('%s', 'now') - 1, 10, NULL)")
+	if err == nil {
+		t.Error("insert should not succeed")
+	}
+	if diff := cmp.Diff(err.Error(), "activity overlaps with existing one"); diff != "" {
+		t.Errorf("expected different error: %v", diff)
+	}
+
+	_, err = db.Exec("INSERT INTO log (name, started, duration, current) VALUES ('test', strftime('%s', 'now') - 60, 120, NULL)")
+	if err == nil {
+		t.Error("insert should not succeed")
+	}
+	if diff := cmp.Diff(err.Error(), "activity overlaps with existing one"); diff != "" {
+		t.Errorf("expected different error: %v", diff)
+	}
+}
+
+// countingStore counts transactions of the store
+type countingStore struct {
+	Store
+	begun int
+}
+
+func (s *countingStore) Beginx() (Tx, error) {
+	s.begun++
+	return s.Store.Beginx()
+}
+
+func TestStore(t *testing.T) {
+	db := sqlx.MustOpen("sqlite3", ":memory:")
+	defer db.Close()
+	store := &countingStore{Store: DBStore(db)}
+	tr := New(store, Options{})
+	err := tr.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	begun := store.begun
+	err = tr.Start("@go", 0, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.begun != begun+1 {
+		t.Errorf("expected start in a transaction of the store: %v", store.begun-begun)
+	}
+	latest, err := tr.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Name != "@go" || !latest.Active() {
+		t.Errorf("unexpected activity: %#v", latest)
+	}
+}
+
+func TestReportFormat(t *testing.T) {
+	day := time.Date(2023, 10, 1, 0, 0, 0, 0, time.Local)
+	report := ReportData{
+		Dates:          DateRange{From: day, To: day},
+		StatusDuration: 5 * time.Minute,
+		Items: []ReportItem{
+			{Name: "@go", Duration: time.Hour},
+			{Name: "@a,b", Duration: 30 * time.Minute},
+		},
+		Total: 90 * time.Minute,
+	}
+
+	out, err := report.Format("csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `type,name,seconds,duration
+activity,@go,3600,01:00
+activity,"@a,b",1800,00:30
+total,Total,5400,01:30
+status,inactive,300,00:05
+`
+	if diff := cmp.Diff(out, expected); diff != "" {
+		t.Errorf("expected different csv: %v", diff)
+	}
+
+	out, err = report.Format("json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data struct {
+		From       string
+		Active     bool
+		Status     string
+		Activities []struct {
+			Name    string
+			Seconds int64
+		}
+		TotalSeconds int64 `json:"total_seconds"`
+	}
+	err = json.Unmarshal([]byte(out), &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.From != "2023-10-01" || data.Active || data.Status != "Inactive for 00:05" {
+		t.Errorf("unexpected json: %v", out)
+	}
+	if len(data.Activities) != 2 || data.Activities[0].Seconds != 3600 || data.TotalSeconds != 5400 {
+		t.Errorf("unexpected json: %v", out)
+	}
+}
+
+func TestEdit(t *testing.T) {
+	db := sqlx.MustOpen("sqlite3", ":memory:")
+	defer db.Close()
+	tr := New(DBStore(db), Options{})
+	tr.Init()
+
+	day := time.Date(2000, 1, 1, 0, 0, 0, 0, time.Local)
+	for i, name := range []string{"@go", "@test"} {
+		_, err := db.Exec(
+			`INSERT INTO log (name, started, duration, current) VALUES (?, ?, ?, NULL)`,
+			name, day.Add(time.Duration(i)*time.Hour).Unix(), 30*60,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	activity, err := tr.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tr.Edit(activity, "", time.Time{}, 90*time.Minute)
+	if diff := cmp.Diff(err.Error(), `activity overlaps with #2 "@test" (01:00 - 01:30)`); diff != "" {
+		t.Errorf("expected different error: %v", diff)
+	}
+
+	_, err = tr.Edit(activity, "@golang", day.Add(10*time.Minute), 50*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	activity, err = tr.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if activity.Name != "@golang" || activity.StartedInt != day.Add(10*time.Minute).Unix() || activity.DurationInt != 50*60 {
+		t.Errorf("unexpected activity: %#v", activity)
+	}
+}
+
+func TestOverlapCurrent(t *testing.T) {
+	db := sqlx.MustOpen("sqlite3", ":memory:")
+	defer db.Close()
+	day := time.Date(2000, 1, 1, 0, 0, 0, 0, time.Local)
+	clock := &testClock{now: day.Add(10 * time.Hour)}
+	tr := New(DBStore(db), Options{Clock: clock})
+	tr.Init()
+
+	err := tr.Start("@go", 0, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.now = day.Add(10*time.Hour + 5*time.Minute)
+	err = tr.Update("", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// current activity is stored until 10:05, but it's running until now
+	clock.now = day.Add(10*time.Hour + 8*time.Minute)
+	_, err = tr.Add("@meeting", day.Add(10*time.Hour+6*time.Minute), time.Minute, "")
+	if diff := cmp.Diff(err.Error(), `activity overlaps with current #1 "@go" (10:00 - now)`); diff != "" {
+		t.Errorf("expected different error: %v", diff)
+	}
+	_, err = db.Exec(
+		`INSERT INTO log (name, started, duration, current) VALUES ('@meeting', ?, 60, NULL)`,
+		day.Add(10*time.Hour+6*time.Minute).Unix(),
+	)
+	if err == nil || err.Error() != "activity overlaps with existing one" {
+		t.Errorf("expected overlap error: %v", err)
+	}
+
+	latest, err := tr.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Name != "@go" || !latest.Active() {
+		t.Errorf("expected current activity to be the latest: %#v", latest)
+	}
+	_, err = tr.Add("@meeting", day.Add(9*time.Hour), time.Hour, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	db := sqlx.MustOpen("sqlite3", ":memory:")
+	defer db.Close()
+
+	// the schema before migrations were introduced
+	db.MustExec(`
+		CREATE TABLE log(
+			id INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			started INTEGER UNIQUE NOT NULL,
+			duration INTEGER NOT NULL DEFAULT 0,
+			current INTEGER UNIQUE DEFAULT 1 CHECK (current IN (1))
+		);
+
+		CREATE TRIGGER on_insert_started INSERT ON log
+		FOR EACH ROW
+		BEGIN
+			SELECT RAISE(ABORT, 'started must be latest')
+			WHERE NEW.started < (SELECT MAX(started + duration) FROM log);
+		END;
+
+		INSERT INTO log (name, started, duration) VALUES ('@test', strftime('%s', 'now') - 60, 60);
+	`)
+
+	tr := New(DBStore(db), Options{})
+	applied, err := tr.Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(migrations) {
+		t.Errorf("expected %v applied migrations, got %v", len(migrations), len(applied))
+	}
+	version, err := dbVersion(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != len(migrations) {
+		t.Errorf("expected version %v, got %v", len(migrations), version)
+	}
+
+	_, err = tr.Add("past +past", time.Now().Add(-time.Hour), time.Minute, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	_ = db.QueryRow(`SELECT count(*) FROM log`).Scan(&count)
+	if count != 2 {
+		t.Errorf("log table should have 2 rows, but it has %v", count)
+	}
+
+	var tags []string
+	err = db.Select(&tags, `SELECT tag FROM activity_tags ORDER BY log_id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(tags, []string{"test", "past"}); diff != "" {
+		t.Errorf("expected different tags: %v", diff)
+	}
+
+	applied, err = tr.Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("expected no applied migrations, got %v", len(applied))
+	}
+
+	db.MustExec(fmt.Sprintf(`PRAGMA user_version = %d`, len(migrations)+1))
+	_, err = tr.Migrate()
+	expected := fmt.Sprintf("database version %d is newer than supported %d", len(migrations)+1, len(migrations))
+	if diff := cmp.Diff(err.Error(), expected); diff != "" {
+		t.Errorf("expected different error: %v", diff)
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	cases := map[string][]string{
+		"@go":                         {"go"},
+		"@work/review +client-x":      {"work/review", "client-x"},
+		"lunch":                       nil,
+		"fix @bug +a +b +a":           {"a", "b"},
+		"@ + +/ @work/":               nil,
+		"+client-x review @work/next": {"client-x"},
+	}
+	for name, expected := range cases {
+		if diff := cmp.Diff(parseTags(name), expected); diff != "" {
+			t.Errorf("%#v: expected different tags: %v", name, diff)
+		}
+	}
+}
+
+func TestSuggestions(t *testing.T) {
+	db := sqlx.MustOpen("sqlite3", ":memory:")
+	defer db.Close()
+	tr := New(DBStore(db), Options{})
+	tr.Init()
+
+	now := time.Now()
+	for _, a := range []struct {
+		name     string
+		ago      time.Duration
+		duration time.Duration
+	}{
+		{"@ancient", 200 * 24 * time.Hour, 8 * time.Hour},
+		{"@old", 10 * 24 * time.Hour, 8 * time.Hour},
+		{"@go", 3 * time.Hour, time.Hour},
+		{"@old", 2 * time.Hour, time.Minute},
+		{"@short", time.Hour, time.Minute},
+	} {
+		_, err := tr.Add(a.name, now.Add(-a.ago), a.duration, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	suggestions, err := tr.Suggestions(90)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range suggestions {
+		names = append(names, s.Name)
+	}
+	if diff := cmp.Diff(names, []string{"@old", "@go", "@short"}); diff != "" {
+		t.Errorf("expected different names: %v", diff)
+	}
+
+	suggestions, err = tr.Suggestions(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suggestions) != 4 || suggestions[3].Name != "@ancient" {
+		t.Errorf("expected @ancient to be the last: %#v", suggestions)
+	}
+	if hint := suggestions[3].Hint(); !strings.HasSuffix(hint, "d ago") {
+		t.Errorf("expected different hint: %v", hint)
+	}
+
+	// a name used today without duration, like one started and switched right away
+	if hint := (Suggestion{Days: tr.daysSince(tr.Now())}).Hint(); hint != "today 00:00" {
+		t.Errorf("expected different hint: %v", hint)
+	}
+	if hint := (Suggestion{Days: tr.daysSince(tr.Today().Add(-time.Minute))}).Hint(); hint != "yesterday" {
+		t.Errorf("expected different hint: %v", hint)
+	}
+}
+
+func TestResume(t *testing.T) {
+	db := sqlx.MustOpen("sqlite3", ":memory:")
+	defer db.Close()
+	tr := New(DBStore(db), Options{})
+	tr.Init()
+
+	started := time.Now().Add(-30 * time.Minute).Truncate(time.Second)
+	_, err := tr.Add("@go", started, 10*time.Minute, "parser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tr.Resume(false, "@break")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var activities []Activity
+	err = db.Select(&activities, `SELECT * FROM log ORDER BY started`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(activities) != 3 {
+		t.Fatalf("expected 3 activities, got %#v", activities)
+	}
+	if a := activities[1]; a.Name != "@break" || a.Started() != started.Add(10*time.Minute) || a.Current.Bool {
+		t.Errorf("unexpected break: %#v", a)
+	}
+	if a := activities[2]; a.Name != "@go" || a.Note != "parser" || !a.Active() || a.StartedInt != activities[1].Updated().Unix() {
+		t.Errorf("unexpected resumed activity: %#v", a)
+	}
+
+	_, err = db.Exec(`UPDATE log SET duration = 0, started = started - 900 WHERE id = ?`, activities[2].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tr.Resume(true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest, err := tr.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.ID != activities[2].ID || !latest.Active() || latest.DurationInt < 900 {
+		t.Errorf("unexpected resumed activity: %#v", latest)
+	}
+}
+
+func TestImport(t *testing.T) {
+	db := sqlx.MustOpen("sqlite3", ":memory:")
+	defer db.Close()
+	tr := New(DBStore(db), Options{})
+	tr.Init()
+
+	day := time.Date(2000, 1, 1, 0, 0, 0, 0, time.Local)
+	_, err := tr.Add("@meeting", day.Add(10*time.Hour), 45*time.Minute, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := day.Add(9 * time.Hour).UTC().Format("20060102T150405Z")
+	ended := day.Add(9*time.Hour + 30*time.Minute).UTC().Format("20060102T150405Z")
+	cases := []struct {
+		format string
+		data   string
+		entry  ImportEntry
+	}{
+		{
+			"timewarrior",
+			`[{"id":1,"start":"` + started + `","end":"` + ended + `","tags":["go","code review"],"annotation":"parser"},{"id":2,"start":"` + ended + `","tags":["go"]}]`,
+			ImportEntry{"@go +code-review", day.Add(9 * time.Hour), 30 * time.Minute, "parser"},
+		},
+		{
+			"toggl-csv",
+			"User,Email,Client,Project,Task,Description,Billable,Start date,Start time,End date,End time,Duration,Tags\n" +
+				"me,me@example.com,,timefor,,parser,No,2000-01-01,09:00:00,2000-01-01,09:30:00,00:30:00,\"go, review\"\n",
+			ImportEntry{"@timefor +go +review", day.Add(9 * time.Hour), 30 * time.Minute, "parser"},
+		},
+		{
+			"timeclock",
+			"; comment\ni 2000/01/01 09:00:00 work:review  parser\no 2000/01/01 09:30:00\ni 2000/01/01 10:00 work\n",
+			ImportEntry{"@work/review", day.Add(9 * time.Hour), 30 * time.Minute, "parser"},
+		},
+	}
+	for _, c := range cases {
+		entries, err := ParseImport(c.format, strings.NewReader(c.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]ImportEntry{c.entry}, entries); diff != "" {
+			t.Errorf("unexpected %v entries: %v", c.format, diff)
+		}
+	}
+
+	_, err = ParseImport("timeclock", strings.NewReader("o 2000/01/01 09:30:00\n"))
+	if err == nil || err.Error() != "cannot parse line 1: clock-out without clock-in" {
+		t.Errorf("expected error for clock-out, got %v", err)
+	}
+
+	entries := []ImportEntry{
+		{"@go", day.Add(9 * time.Hour), 30 * time.Minute, "parser"},
+		{"@call", day.Add(10*time.Hour + 30*time.Minute), time.Hour, ""},
+		{"@go", day.Add(9*time.Hour + 15*time.Minute), time.Hour, ""},
+	}
+	out, err := tr.Import(entries, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `Started           Duration  Name   Note    Status
+2000-01-01 09:00  00:30     @go    parser  new
+2000-01-01 10:30  01:00     @call          skipped: activity overlaps with #1 "@meeting" (10:00 - 10:45)
+2000-01-01 09:15  01:00     @go            skipped: activity overlaps with #2 "@go" (09:00 - 09:30)
+1 activities would be imported, 2 skipped
+`
+	if diff := cmp.Diff(expected, out); diff != "" {
+		t.Errorf("unexpected dry run output: %v", diff)
+	}
+
+	var count int
+	err = db.Get(&count, `SELECT count(*) FROM log`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("expected nothing imported on dry run, got %v activities", count)
+	}
+	out, err = tr.Import(entries, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(out, "1 activities imported, 2 skipped\n") {
+		t.Errorf("unexpected output: %v", out)
+	}
+	err = db.Get(&count, `SELECT count(*) FROM activity_tags WHERE tag = 'go'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("expected tags of imported activity, got %v", count)
+	}
+}
+
+func TestExport(t *testing.T) {
+	db := sqlx.MustOpen("sqlite3", ":memory:")
+	defer db.Close()
+	tr := New(DBStore(db), Options{})
+	tr.Init()
+
+	day := time.Date(2000, 1, 1, 0, 0, 0, 0, time.Local)
+	_, err := tr.Add("@work/review +go", day.Add(10*time.Hour), 45*time.Minute, "parser, tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tr.Add("@work/review", day.Add(11*time.Hour), 90*time.Minute, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dates := DateRange{From: day, To: day}
+
+	out, err := tr.Export(dates, "timeclock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `i 2000/01/01 10:00:00 work:review  +go parser, tests
+o 2000/01/01 10:45:00
+i 2000/01/01 11:00:00 work:review
+o 2000/01/01 12:30:00
+`
+	if diff := cmp.Diff(expected, out); diff != "" {
+		t.Errorf("unexpected timeclock: %v", diff)
+	}
+	entries, err := parseTimeclock(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[1].Name != "@work/review" || entries[1].Duration != 90*time.Minute {
+		t.Errorf("unexpected entries imported back: %#v", entries)
+	}
+
+	out, err = tr.Export(dates, "org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `* @work/review +go
+  :LOGBOOK:
+  CLOCK: [2000-01-01 Sat 10:00]--[2000-01-01 Sat 10:45] =>  0:45
+  :END:
+* @work/review
+  :LOGBOOK:
+  CLOCK: [2000-01-01 Sat 11:00]--[2000-01-01 Sat 12:30] =>  1:30
+  :END:
+`
+	if diff := cmp.Diff(expected, out); diff != "" {
+		t.Errorf("unexpected org: %v", diff)
+	}
+
+	out, err = tr.Export(dates, "ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := day.Add(10 * time.Hour).UTC().Format("20060102T150405Z")
+	for _, line := range []string{"BEGIN:VCALENDAR\r\n", "DTSTART:" + start + "\r\n", "SUMMARY:@work/review +go\r\n", `DESCRIPTION:parser\, tests` + "\r\n"} {
+		if !strings.Contains(out, line) {
+			t.Errorf("expected %q in ics: %v", line, out)
+		}
+	}
+	if folded := foldICSLine(strings.Repeat("é", 40)); strings.Index(folded, "\r\n ") != 74 {
+		t.Errorf("unexpected folding: %q", folded)
+	}
+
+	out, err = tr.Export(dates, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []map[string]interface{}
+	err = json.Unmarshal([]byte(out), &items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0]["seconds"] != float64(45*60) || items[0]["note"] != "parser, tests" {
+		t.Errorf("unexpected json: %v", out)
+	}
+
+	// a blank name like from "timefor start ' '"
+	nextDay := day.AddDate(0, 0, 1)
+	db.MustExec(`INSERT INTO log (name, started, duration, current) VALUES ('', ?, 600, NULL)`, nextDay.Add(10*time.Hour).Unix())
+	out, err = tr.Export(DateRange{From: nextDay, To: nextDay}, "timeclock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `i 2000/01/02 10:00:00 unnamed
+o 2000/01/02 10:10:00
+`
+	if diff := cmp.Diff(expected, out); diff != "" {
+		t.Errorf("unexpected timeclock: %v", diff)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	db := sqlx.MustOpen("sqlite3", ":memory:")
+	defer db.Close()
+	tr := New(DBStore(db), Options{})
+	tr.Init()
+
+	_, err := tr.Undo()
+	if err == nil || err.Error() != "nothing to undo" {
+		t.Errorf("expected nothing to undo, got %v", err)
+	}
+	err = tr.Start("@go", 20*time.Minute, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tr.Update("@golang +parser", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tr.Reject()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = tr.Undo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest, err := tr.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Name != "@golang +parser" || !latest.Active() {
+		t.Errorf("expected restored activity: %#v", latest)
+	}
+	_, err = tr.Undo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest, err = tr.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tags []string
+	err = db.Select(&tags, `SELECT tag FROM activity_tags WHERE log_id = ?`, latest.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Name != "@go" || len(tags) != 1 || tags[0] != "go" {
+		t.Errorf("expected the name and tags before update: %#v %v", latest, tags)
+	}
+
+	err = tr.Start("@test", 0, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tr.Undo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest, err = tr.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Name != "@go" || !latest.Active() {
+		t.Errorf("expected the activity before start: %#v", latest)
+	}
+
+	out, err := tr.History(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(out, "\n")
+	if len(lines) != 4 || !strings.Contains(lines[1], "start    #1 @go, #2 @test (new)") || !strings.Contains(lines[2], "reject   #1 @golang +parser (deleted)") || !strings.HasSuffix(lines[2], "undone") {
+		t.Errorf("unexpected history:\n%v", out)
+	}
+
+	// finishing by the daemon, like after idle
+	err = tr.FinishAt(latest, tr.Now().Add(-5*time.Minute), "idle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err = tr.Undo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest, err = tr.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "Command \"idle\" undone\n" || latest.Name != "@go" || !latest.Active() {
+		t.Errorf("expected the activity before idle: %q %#v", out, latest)
+	}
+}
+
+func TestGoals(t *testing.T) {
+	db := sqlx.MustOpen("sqlite3", ":memory:")
+	defer db.Close()
+	tr := New(DBStore(db), Options{})
+	tr.Init()
+
+	_, err := tr.Add("@work/review", time.Now().Add(-3*time.Minute), 2*time.Minute, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tr.SetGoal("@work", "day", false, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tr.SetGoal("+surf", "week", true, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tr.SetGoal("review", "week", true, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	activity, err := tr.WithGoals(Activity{Name: "@work/review +surf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(activity.Goals) != 2 {
+		t.Fatalf("expected goals for work and surf: %#v", activity.Goals)
+	}
+	if g := activity.Goals[0]; g.Tag != "work" || g.Status() != "reached" || activity.GoalProgress() != "00:02/00:01" {
+		t.Errorf("unexpected daily goal %v: %#v", activity.GoalProgress(), g)
+	}
+	if g := activity.Goals[1]; g.Tag != "surf" || g.Done() || g.Spent != 0 {
+		t.Errorf("unexpected weekly limit: %#v", g)
+	}
+
+	err = tr.DeleteGoal("surf", "day")
+	if err == nil || err.Error() != `no goal for "surf" per day` {
+		t.Errorf("expected error for unknown goal, got %v", err)
+	}
+}
+
+func TestDayStart(t *testing.T) {
+	db := sqlx.MustOpen("sqlite3", ":memory:")
+	defer db.Close()
+	tr := New(DBStore(db), Options{DayStart: 4 * time.Hour})
+	tr.Init()
+
+	day := time.Date(2000, 1, 1, 0, 0, 0, 0, time.Local)
+	_, err := tr.Add("@late", day.Add(23*time.Hour+30*time.Minute), 4*time.Hour, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tr.Add("@split", day.AddDate(0, 0, 2).Add(3*time.Hour), 2*time.Hour, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type dailyRow struct {
+		Date     string
+		Name     string
+		Duration int64
+	}
+	// views use the stored day start, Init doesn't change it
+	err = tr.StoreDayStart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	New(DBStore(db), Options{}).Init()
+	var daily []dailyRow
+	err = db.Select(&daily, `SELECT date, name, duration FROM log_daily ORDER BY date`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []dailyRow{
+		{"2000-01-01", "@late", 4 * 3600},
+		{"2000-01-02", "@split", 3600},
+		{"2000-01-03", "@split", 3600},
+	}
+	if diff := cmp.Diff(expected, daily); diff != "" {
+		t.Errorf("unexpected daily totals: %v", diff)
+	}
+	// reports use the day start of the tracker, not the stored one
+	err = New(DBStore(db), Options{}).StoreDayStart()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := tr.Report(ReportOptions{Dates: DateRange{From: day.AddDate(0, 0, 1), To: day.AddDate(0, 0, 1)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Items) != 1 || report.Items[0].Name != "@split" || report.Total != time.Hour {
+		t.Errorf("expected the part of split activity only: %#v", report)
+	}
+	out, err := tr.List(DateRange{From: day.AddDate(0, 0, 1), To: day.AddDate(0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "@split") || strings.Contains(out, "@late") {
+		t.Errorf("unexpected list: %v", out)
+	}
+	out, err = tr.Export(DateRange{From: day.AddDate(0, 0, 1), To: day.AddDate(0, 0, 1)}, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "@split") || strings.Contains(out, "@late") {
+		t.Errorf("unexpected export: %v", out)
+	}
+}
+
+// testClock is a clock which can be moved in tests
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func TestClock(t *testing.T) {
+	db := sqlx.MustOpen("sqlite3", ":memory:")
+	defer db.Close()
+	day := time.Date(2000, 1, 1, 0, 0, 0, 0, time.Local)
+	clock := &testClock{now: day.Add(9 * time.Hour)}
+	tr := New(DBStore(db), Options{Clock: clock})
+	tr.Init()
+
+	err := tr.Start("@go", 0, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.now = day.Add(9*time.Hour + 5*time.Minute)
+	err = tr.Update("", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.now = day.Add(9*time.Hour + 15*time.Minute)
+	latest, err := tr.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Expired() || latest.Duration() != 15*time.Minute {
+		t.Errorf("expected active activity for 15m: %#v", latest)
+	}
+	clock.now = day.Add(9*time.Hour + 16*time.Minute)
+	if !latest.Expired() || latest.Duration() != 5*time.Minute || latest.TimeSince() != 11*time.Minute {
+		t.Errorf("expected expired activity after 5m: %#v", latest)
+	}
+	// another tracker of the same database with its own expire interval
+	latest, err = New(DBStore(db), Options{IntervalToExpire: time.Hour, Clock: clock}).Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !latest.Active() || latest.Duration() != 16*time.Minute {
+		t.Errorf("expected active activity with an hour to expire: %#v", latest)
+	}
+
+	report, err := tr.Report(ReportOptions{Dates: DateRange{From: tr.Today(), To: tr.Today()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Dates.FromDate() != "2000-01-01" || report.Active || report.Total != 5*time.Minute {
+		t.Errorf("unexpected report: %#v", report)
+	}
+}
+
+func TestMidnight(t *testing.T) {
+	db := sqlx.MustOpen("sqlite3", ":memory:")
+	defer db.Close()
+	day := time.Date(2000, 1, 1, 0, 0, 0, 0, time.Local)
+	tr := New(DBStore(db), Options{Clock: FixedClock(day.AddDate(0, 0, 1).Add(4 * time.Hour))})
+	tr.Init()
+
+	_, err := tr.Add("@late", day.Add(23*time.Hour), 3*time.Hour, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expected := range []time.Duration{time.Hour, 2 * time.Hour} {
+		date := day.AddDate(0, 0, i)
+		report, err := tr.Report(ReportOptions{Dates: DateRange{From: date, To: date}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Items) != 1 || report.Items[0].Duration != expected || report.Total != expected {
+			t.Errorf("expected %v for %v: %#v", expected, date, report)
+		}
+	}
+
+	suggestions, err := tr.Suggestions(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suggestions) != 1 || suggestions[0].Hint() != "today 02:00" {
+		t.Errorf("expected today's part only: %#v", suggestions)
+	}
+}
+
+func TestTimesheet(t *testing.T) {
+	db := sqlx.MustOpen("sqlite3", ":memory:")
+	defer db.Close()
+	monday := time.Date(2000, 1, 3, 0, 0, 0, 0, time.Local)
+	tr := New(DBStore(db), Options{Clock: FixedClock(monday.AddDate(0, 0, 7))})
+	tr.Init()
+
+	for _, a := range []struct {
+		name     string
+		started  time.Duration
+		duration time.Duration
+	}{
+		{"@work +go", 10 * time.Hour, 2 * time.Hour},
+		{"@work", 24*time.Hour + 10*time.Hour, time.Hour},
+		{"@call", 24*time.Hour + 23*time.Hour, 2 * time.Hour},
+	} {
+		_, err := tr.Add(a.name, monday.Add(a.started), a.duration, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := tr.Report(ReportOptions{
+		Dates:     DateRange{From: monday, To: monday.AddDate(0, 0, 2)},
+		ByTag:     true,
+		Timesheet: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `       Mon 01-03  Tue 01-04  Wed 01-05  Total
+call              01:00      01:00      02:00
+go     02:00                            02:00
+work   02:00      01:00                 03:00
+Total  02:00      02:00      01:00      05:00
+`
+	if diff := cmp.Diff(expected, report.Text()); diff != "" {
+		t.Errorf("unexpected timesheet: %v", diff)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	db := sqlx.MustOpen("sqlite3", ":memory:")
+	defer db.Close()
+	monday := time.Date(2000, 1, 3, 0, 0, 0, 0, time.Local)
+	tr := New(DBStore(db), Options{Clock: FixedClock(monday.AddDate(0, 0, 7))})
+	tr.Init()
+
+	for _, a := range []struct {
+		name     string
+		started  time.Duration
+		duration time.Duration
+	}{
+		{"@work <b>", 10 * time.Hour, 2 * time.Hour},
+		{"@call", 24*time.Hour + 23*time.Hour, 2 * time.Hour},
+	} {
+		_, err := tr.Add(a.name, monday.Add(a.started), a.duration, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page, err := tr.HTML(ReportOptions{Dates: DateRange{From: monday, To: monday.AddDate(0, 0, 2)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"<title>Report for 2000-01-03..2000-01-05</title>",
+		// timeline: "@work" at 10:00 on Monday
+		`<rect x="450" y="20" width="72" height="16" fill="#f28e2b"><title>@work &lt;b&gt; 10:00 - 12:00 (02:00)</title></rect>`,
+		// timeline: "@call" is split at midnight
+		`<rect x="918" y="42" width="36" height="16" fill="#4e79a7"><title>@call 23:00 - 00:00 (01:00)</title></rect>`,
+		`<rect x="90" y="64" width="36" height="16" fill="#4e79a7"><title>@call 00:00 - 01:00 (01:00)</title></rect>`,
+		// totals
+		`<td>@call</td>
+<td class="duration">02:00</td>`,
+		`<th>Total</th><th class="duration">04:00</th>`,
+		// daily: "@work" is the whole Monday
+		`<rect x="46" y="10" width="28" height="200" fill="#f28e2b"><title>2000-01-03 @work &lt;b&gt; (02:00)</title></rect>`,
+	} {
+		if !strings.Contains(page, s) {
+			t.Errorf("no %q in the page:\n%v", s, page)
+		}
+	}
+	for _, s := range []string{"href=", "src=", "<script"} {
+		if strings.Contains(page, s) {
+			t.Errorf("unexpected %q in the page", s)
+		}
+	}
+
+	// grouped by tags an activity is stacked once per tag, so Wednesday
+	// is 07:00 in the daily chart with 04:00 in total
+	_, err = tr.Add("@review +client", monday.AddDate(0, 0, 2).Add(10*time.Hour), 3*time.Hour, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err = tr.HTML(ReportOptions{Dates: DateRange{From: monday, To: monday.AddDate(0, 0, 2)}, ByTag: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(page, ` y="-`) {
+		t.Errorf("daily columns overflow the chart:\n%v", page)
+	}
+	if !strings.Contains(page, `text-anchor="end">8h</text>`) {
+		t.Errorf("expected the scale up to 8h:\n%v", page)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/naspeh/timefor/tracker"
+)
+
+// watchFormats are formats of status lines for Watch
+var watchFormats = []string{"plain", "i3bar", "waybar"}
+
+type i3barBlock struct {
+	Name     string `json:"name"`
+	FullText string `json:"full_text"`
+	Urgent   bool   `json:"urgent,omitempty"`
+}
+
+type waybarStatus struct {
+	Text    string `json:"text"`
+	Tooltip string `json:"tooltip,omitempty"`
+	Class   string `json:"class"`
+}
+
+// Watch prints a status line whenever current activity is changed or
+// its minute counter ticks, it's driven by changes of the database file
+func Watch(tr *tracker.Tracker, format, tpl string) error {
+	change := make(chan ChangeEvent)
+	go watchDbFile(change)
+
+	if format == "i3bar" {
+		// the header and the start of the infinite array of i3bar protocol
+		fmt.Println(`{"version":1}`)
+		fmt.Println("[")
+	}
+	var last string
+	for {
+		activity, err := tr.Latest()
+		if err != nil {
+			return err
+		}
+		activity, err = tr.WithGoals(activity)
+		if err != nil {
+			return err
+		}
+		line, err := statusLine(activity, format, tpl)
+		if err != nil {
+			return err
+		}
+		if line != last {
+			if format == "i3bar" && last != "" {
+				fmt.Print(",")
+			}
+			fmt.Println(line)
+			last = line
+		}
+
+		nextUpdate := activity.TimeSince().Truncate(time.Minute) + time.Minute - activity.TimeSince()
+		select {
+		case c := <-change:
+			if c.Error != nil {
+				return c.Error
+			}
+		case <-time.After(nextUpdate):
+		}
+	}
+}
+
+// statusLine formats the activity using the template for the status bar
+func statusLine(activity tracker.Activity, format, tpl string) (string, error) {
+	text, err := activity.Format(tpl)
+	if err != nil {
+		return "", err
+	}
+	overdue := activity.Active() && activity.PlannedInt > 0 && activity.Countdown() < 0
+
+	var data []byte
+	switch format {
+	case "plain":
+		return text, nil
+	case "i3bar":
+		data, err = json.Marshal([]i3barBlock{{Name: "timefor", FullText: text, Urgent: overdue}})
+	case "waybar":
+		status := waybarStatus{Text: text, Tooltip: activity.Note, Class: "inactive"}
+		if overdue {
+			status.Class = "overdue"
+		} else if activity.Active() {
+			status.Class = "active"
+		}
+		data, err = json.Marshal(status)
+	default:
+		return "", fmt.Errorf("unknown format %#v", format)
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}